@@ -3,6 +3,7 @@ module github.com/ytuox/elink-sdk-go
 go 1.22.0
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/antlabs/timer v0.1.3
 	github.com/goburrow/serial v0.1.0
 	github.com/golang/snappy v0.0.4
@@ -10,12 +11,13 @@ require (
 	github.com/spf13/cast v1.6.0
 	github.com/ytuox/elink-plugin-proto v0.0.1
 	go.uber.org/zap v1.27.0
+	golang.org/x/crypto v0.21.0
 	google.golang.org/grpc v1.63.2
 	google.golang.org/protobuf v1.33.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
-	github.com/BurntSushi/toml v1.3.2 // indirect
 	github.com/antlabs/stl v0.0.2 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	golang.org/x/net v0.22.0 // indirect
@@ -23,5 +25,4 @@ require (
 	golang.org/x/text v0.14.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
@@ -0,0 +1,27 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package interfaces
+
+import (
+	"context"
+
+	"github.com/ytuox/elink-sdk-go/model"
+)
+
+// DownlinkHandler 下行消息处理函数
+type DownlinkHandler func(ctx context.Context, msg *model.DownlinkMessage) error
+
+// Middleware 下行消息中间件,在调用插件的 HandlePropertySet/HandlePropertyGet/HandleServiceExecute 前后执行
+type Middleware func(next DownlinkHandler) DownlinkHandler
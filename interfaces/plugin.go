@@ -37,3 +37,14 @@ type Plugin interface {
 	//HandleServiceExecute 设备服务调用
 	HandleServiceExecute(ctx context.Context, deviceId string, data model.ServiceExecuteRequest) error
 }
+
+// ParamsAware 插件可选实现的接口,插件参数变化时调用。
+// 调用过 PluginService.Params 时old与new为同类型的结构体指针,否则为JSON字符串
+type ParamsAware interface {
+	ParamsChanged(ctx context.Context, old, new interface{})
+}
+
+// LeaderAware 主备模式下插件可选实现的接口,实例成为主实例或备实例时按顺序异步调用,只有主实例应轮询设备
+type LeaderAware interface {
+	LeadershipChanged(ctx context.Context, leader bool)
+}
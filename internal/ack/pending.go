@@ -0,0 +1,118 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package ack
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/common"
+)
+
+const DefaultTimeout = 10 * time.Second
+
+var ErrTimeout = errors.New("wait for ack timeout")
+
+// Pending 等待核心服务确认的上行消息
+type Pending struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	waiters map[string]*common.MsgAckChan
+}
+
+func NewPending() *Pending {
+	return &Pending{
+		timeout: DefaultTimeout,
+		waiters: make(map[string]*common.MsgAckChan),
+	}
+}
+
+func (p *Pending) SetTimeout(timeout time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	p.timeout = timeout
+}
+
+// Add 在发送消息前登记,避免确认先于发送结果到达
+func (p *Pending) Add(deviceId, msgId string) *common.MsgAckChan {
+	mac := &common.MsgAckChan{
+		Id:       key(deviceId, msgId),
+		DataChan: make(chan interface{}, 1),
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if old, ok := p.waiters[mac.Id]; ok {
+		old.TryCloseChan()
+	}
+	p.waiters[mac.Id] = mac
+	return mac
+}
+
+// Wait 等待确认消息,超时返回 ErrTimeout
+func (p *Pending) Wait(mac *common.MsgAckChan) (string, error) {
+	p.mu.Lock()
+	timeout := p.timeout
+	p.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	defer p.remove(mac)
+
+	select {
+	case data, ok := <-mac.DataChan:
+		if !ok {
+			return "", ErrTimeout
+		}
+		return data.(string), nil
+	case <-timer.C:
+		return "", ErrTimeout
+	}
+}
+
+// Cancel 消息未发送成功时取消等待
+func (p *Pending) Cancel(mac *common.MsgAckChan) {
+	p.remove(mac)
+	mac.TryCloseChan()
+}
+
+// Done 收到核心服务的确认消息,没有等待者时返回false
+func (p *Pending) Done(deviceId, msgId, data string) bool {
+	p.mu.Lock()
+	mac, ok := p.waiters[key(deviceId, msgId)]
+	if ok {
+		delete(p.waiters, mac.Id)
+	}
+	p.mu.Unlock()
+	if !ok {
+		return false
+	}
+	return mac.TrySendDataAndCloseChan(data)
+}
+
+func (p *Pending) remove(mac *common.MsgAckChan) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.waiters[mac.Id] == mac {
+		delete(p.waiters, mac.Id)
+	}
+}
+
+func key(deviceId, msgId string) string {
+	return deviceId + "/" + msgId
+}
@@ -0,0 +1,110 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package ack
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitReceivesAck(t *testing.T) {
+	p := NewPending()
+	mac := p.Add("d1", "m1")
+	go p.Done("d1", "m1", `{"success":true}`)
+
+	data, err := p.Wait(mac)
+	if err != nil || data != `{"success":true}` {
+		t.Fatalf("Wait() = %q, %v, want the ack payload", data, err)
+	}
+}
+
+func TestAckBeforeWait(t *testing.T) {
+	p := NewPending()
+	mac := p.Add("d1", "m1")
+	// 确认先于发送结果到达
+	if !p.Done("d1", "m1", "ok") {
+		t.Fatal("Done() = false, want the registered waiter")
+	}
+	if data, err := p.Wait(mac); err != nil || data != "ok" {
+		t.Fatalf("Wait() = %q, %v, want the early ack", data, err)
+	}
+}
+
+func TestWaitTimeoutAndLateAck(t *testing.T) {
+	p := NewPending()
+	p.SetTimeout(20 * time.Millisecond)
+	mac := p.Add("d1", "m1")
+
+	start := time.Now()
+	if _, err := p.Wait(mac); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Wait() error = %v, want %v", err, ErrTimeout)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("Wait() returned after %s, want the configured timeout", elapsed)
+	}
+	if p.Done("d1", "m1", "late") {
+		t.Fatal("Done() after timeout = true, want the late ack dropped")
+	}
+}
+
+func TestDoneMatchesDeviceAndMsgId(t *testing.T) {
+	p := NewPending()
+	p.SetTimeout(20 * time.Millisecond)
+	mac := p.Add("d1", "m1")
+	if p.Done("d2", "m1", "ok") || p.Done("d1", "m2", "ok") {
+		t.Fatal("Done() matched another device or message")
+	}
+	if _, err := p.Wait(mac); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Wait() error = %v, want %v", err, ErrTimeout)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	p := NewPending()
+	mac := p.Add("d1", "m1")
+	p.Cancel(mac)
+	if p.Done("d1", "m1", "ok") {
+		t.Fatal("Done() after Cancel = true")
+	}
+	if _, err := p.Wait(mac); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Wait() after Cancel error = %v, want %v", err, ErrTimeout)
+	}
+}
+
+func TestAddReplacesWaiter(t *testing.T) {
+	p := NewPending()
+	old := p.Add("d1", "m1")
+	mac := p.Add("d1", "m1")
+
+	// 被替换的等待者立即返回
+	if _, err := p.Wait(old); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Wait() of replaced waiter error = %v, want %v", err, ErrTimeout)
+	}
+	if !p.Done("d1", "m1", "ok") {
+		t.Fatal("Done() = false, want the new waiter")
+	}
+	if data, err := p.Wait(mac); err != nil || data != "ok" {
+		t.Fatalf("Wait() = %q, %v", data, err)
+	}
+}
+
+func TestSetTimeoutDefault(t *testing.T) {
+	p := NewPending()
+	p.SetTimeout(0)
+	if p.timeout != DefaultTimeout {
+		t.Fatalf("timeout = %s, want %s", p.timeout, DefaultTimeout)
+	}
+}
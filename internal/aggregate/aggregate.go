@@ -0,0 +1,318 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package aggregate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/model"
+
+	"github.com/spf13/cast"
+)
+
+const tick = 100 * time.Millisecond
+
+// EmitFunc 窗口结束时输出聚合结果
+type EmitFunc func(deviceId string, ts int64, data map[string]interface{})
+
+type sample struct {
+	ts    time.Time
+	value interface{}
+}
+
+// emission 同一设备同一窗口结束时间的聚合结果合并为一次上报
+type emission struct {
+	deviceId string
+	end      int64
+}
+
+type series struct {
+	productId string
+	samples   []sample
+	nextEmit  time.Time
+}
+
+// Aggregator 按产品属性配置的时间窗口聚合属性值
+type Aggregator struct {
+	mu       sync.Mutex
+	once     sync.Once
+	ctx      context.Context
+	emit     EmitFunc
+	policies map[string]map[string]model.AggregatePolicy
+	series   map[string]map[string]*series
+	now      func() time.Time
+}
+
+func NewAggregator(ctx context.Context, emit EmitFunc) *Aggregator {
+	return &Aggregator{
+		ctx:      ctx,
+		emit:     emit,
+		policies: make(map[string]map[string]model.AggregatePolicy),
+		series:   make(map[string]map[string]*series),
+		now:      time.Now,
+	}
+}
+
+// SetClock 设置采样与窗口到期使用的时钟,定时器仍按系统时间每隔100ms检查一次
+func (a *Aggregator) SetClock(now func() time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.now = now
+}
+
+func (a *Aggregator) SetPolicy(productId, identifier string, policy model.AggregatePolicy) error {
+	if policy.Window <= 0 {
+		return errors.New("required aggregate window")
+	}
+	if len(policy.Functions) == 0 {
+		return errors.New("required aggregate functions")
+	}
+	targets, err := outputs(identifier, policy)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	pm, ok := a.policies[productId]
+	if !ok {
+		pm = make(map[string]model.AggregatePolicy)
+		a.policies[productId] = pm
+	}
+	if err = checkTargets(pm, identifier, targets); err != nil {
+		a.mu.Unlock()
+		return err
+	}
+	pm[identifier] = policy
+	a.mu.Unlock()
+
+	a.once.Do(func() {
+		go a.run()
+	})
+	return nil
+}
+
+// outputs 返回聚合函数的输出属性,输出标识为空时使用原属性标识,不同的聚合函数不能输出到同一属性
+func outputs(identifier string, policy model.AggregatePolicy) (map[string]model.AggregateFunc, error) {
+	targets := make(map[string]model.AggregateFunc, len(policy.Functions))
+	for fn, target := range policy.Functions {
+		if target == "" {
+			target = identifier
+		}
+		if other, ok := targets[target]; ok {
+			return nil, fmt.Errorf("aggregate functions %s and %s output to the same property %s", other, fn, target)
+		}
+		targets[target] = fn
+	}
+	return targets, nil
+}
+
+// checkTargets 同一产品的聚合结果合并上报,各策略的输出属性不能相同,也不能是其他策略聚合的原始属性
+func checkTargets(pm map[string]model.AggregatePolicy, identifier string, targets map[string]model.AggregateFunc) error {
+	for other, policy := range pm {
+		if other == identifier {
+			continue
+		}
+		if _, ok := targets[other]; ok {
+			return fmt.Errorf("aggregate output %s is aggregated by its own policy", other)
+		}
+		otherTargets, _ := outputs(other, policy)
+		for target := range otherTargets {
+			if target == identifier {
+				return fmt.Errorf("property %s is the aggregate output of %s", identifier, other)
+			}
+			if _, ok := targets[target]; ok {
+				return fmt.Errorf("aggregate policies of %s and %s output to the same property %s", other, identifier, target)
+			}
+		}
+	}
+	return nil
+}
+
+func (a *Aggregator) RemovePolicy(productId, identifier string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.policies[productId], identifier)
+}
+
+func (a *Aggregator) RemoveById(deviceId string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.series, deviceId)
+}
+
+// Add 记录原始属性值,返回未配置聚合策略、需要直接上报的属性
+func (a *Aggregator) Add(productId, deviceId string, data map[string]interface{}) map[string]interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	pm := a.policies[productId]
+	rest := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		policy, ok := pm[k]
+		if !ok {
+			rest[k] = v
+			continue
+		}
+		sm, ok := a.series[deviceId]
+		if !ok {
+			sm = make(map[string]*series)
+			a.series[deviceId] = sm
+		}
+		s, ok := sm[k]
+		if !ok {
+			s = &series{productId: productId, nextEmit: now.Add(policy.GetStep())}
+			sm[k] = s
+		}
+		s.samples = append(s.samples, sample{ts: now, value: v})
+	}
+	return rest
+}
+
+func (a *Aggregator) run() {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			a.mu.Lock()
+			now := a.now()
+			a.mu.Unlock()
+			for e, data := range a.collect(now) {
+				a.emit(e.deviceId, e.end, data)
+			}
+		}
+	}
+}
+
+// collect 计算所有到期窗口的聚合结果,以窗口结束时间作为上报时间
+func (a *Aggregator) collect(now time.Time) map[emission]map[string]interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	out := make(map[emission]map[string]interface{})
+	for deviceId, sm := range a.series {
+		for identifier, s := range sm {
+			// 使用当前的策略,策略删除后丢弃未输出的采样点
+			policy, ok := a.policies[s.productId][identifier]
+			if !ok {
+				delete(sm, identifier)
+				continue
+			}
+			if now.Before(s.nextEmit) {
+				continue
+			}
+			// 窗口以到期时间为界,到期后、定时器触发前收到的采样点属于下一个窗口
+			end := s.nextEmit
+			start := end.Add(-policy.Window)
+			window := s.samples[:0:0]
+			for _, smp := range s.samples {
+				if !smp.ts.Before(start) && smp.ts.Before(end) {
+					window = append(window, smp)
+				}
+			}
+			if len(window) == 0 && len(s.samples) == 0 {
+				delete(sm, identifier)
+				continue
+			}
+			if len(window) > 0 {
+				e := emission{deviceId: deviceId, end: end.UnixMilli()}
+				data, ok := out[e]
+				if !ok {
+					data = make(map[string]interface{})
+					out[e] = data
+				}
+				for fn, target := range policy.Functions {
+					if target == "" {
+						target = identifier
+					}
+					if v, ok := compute(fn, window); ok {
+						data[target] = v
+					}
+				}
+			}
+
+			// 保留下个窗口仍需要的采样点
+			keep := end
+			if policy.Type == model.WindowSliding {
+				keep = end.Add(policy.GetStep()).Add(-policy.Window)
+			}
+			s.samples = dropBefore(s.samples, keep)
+			s.nextEmit = end.Add(policy.GetStep())
+			if s.nextEmit.Before(now) {
+				s.nextEmit = now.Add(policy.GetStep())
+			}
+		}
+		if len(sm) == 0 {
+			delete(a.series, deviceId)
+		}
+	}
+	return out
+}
+
+func dropBefore(samples []sample, t time.Time) []sample {
+	i := 0
+	for i < len(samples) && samples[i].ts.Before(t) {
+		i++
+	}
+	return append(samples[:0:0], samples[i:]...)
+}
+
+func compute(fn model.AggregateFunc, samples []sample) (interface{}, bool) {
+	switch fn {
+	case model.AggregateCount:
+		return len(samples), true
+	case model.AggregateLast:
+		return samples[len(samples)-1].value, true
+	}
+
+	var (
+		n   int
+		sum float64
+		min = math.Inf(1)
+		max = math.Inf(-1)
+	)
+	for _, s := range samples {
+		v, err := cast.ToFloat64E(s.value)
+		if err != nil {
+			continue
+		}
+		n++
+		sum += v
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	if n == 0 {
+		return nil, false
+	}
+	switch fn {
+	case model.AggregateMin:
+		return min, true
+	case model.AggregateMax:
+		return max, true
+	case model.AggregateSum:
+		return sum, true
+	case model.AggregateAvg:
+		return sum / float64(n), true
+	}
+	return nil, false
+}
@@ -0,0 +1,207 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package aggregate
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/model"
+)
+
+// newAggregator 返回不自动输出的聚合器,测试中直接调用 collect
+func newAggregator() *Aggregator {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return NewAggregator(ctx, func(string, int64, map[string]interface{}) {})
+}
+
+// deviceData 合并设备在各窗口的聚合结果
+func deviceData(out map[emission]map[string]interface{}, deviceId string) map[string]interface{} {
+	data := make(map[string]interface{})
+	for e, d := range out {
+		if e.deviceId != deviceId {
+			continue
+		}
+		for k, v := range d {
+			data[k] = v
+		}
+	}
+	return data
+}
+
+func TestSetPolicyRejectsCollidingTargets(t *testing.T) {
+	tests := []struct {
+		name      string
+		functions map[model.AggregateFunc]string
+		wantErr   bool
+	}{
+		{"distinct targets", map[model.AggregateFunc]string{model.AggregateMin: "", model.AggregateMax: "temp_max"}, false},
+		{"both default to identifier", map[model.AggregateFunc]string{model.AggregateMin: "", model.AggregateMax: ""}, true},
+		{"explicit identifier collides with default", map[model.AggregateFunc]string{model.AggregateMin: "", model.AggregateMax: "temp"}, true},
+		{"same explicit target", map[model.AggregateFunc]string{model.AggregateMin: "t", model.AggregateMax: "t"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newAggregator().SetPolicy("p1", "temp", model.NewAggregatePolicy(time.Second, tt.functions))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSetPolicyRejectsTargetsAcrossPolicies(t *testing.T) {
+	functions := func(fn model.AggregateFunc, target string) map[model.AggregateFunc]string {
+		return map[model.AggregateFunc]string{fn: target}
+	}
+	tests := []struct {
+		name       string
+		identifier string
+		functions  map[model.AggregateFunc]string
+		wantErr    bool
+	}{
+		{"distinct targets", "humidity", functions(model.AggregateAvg, "humidity_avg"), false},
+		{"same target as other policy", "humidity", functions(model.AggregateAvg, "temp_avg"), true},
+		{"output to aggregated property", "humidity", functions(model.AggregateAvg, "temp"), true},
+		{"aggregate the output of other policy", "temp_avg", functions(model.AggregateLast, ""), true},
+		{"replace own policy", "temp", functions(model.AggregateMax, "temp_avg"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAggregator()
+			if err := a.SetPolicy("p1", "temp", model.NewAggregatePolicy(time.Second, functions(model.AggregateAvg, "temp_avg"))); err != nil {
+				t.Fatal(err)
+			}
+			err := a.SetPolicy("p1", tt.identifier, model.NewAggregatePolicy(time.Second, tt.functions))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			// 其他产品的策略互不影响
+			if err = a.SetPolicy("p2", tt.identifier, model.NewAggregatePolicy(time.Second, tt.functions)); err != nil {
+				t.Fatalf("SetPolicy() on another product error = %v", err)
+			}
+		})
+	}
+}
+
+func TestEmitUsesWindowEnd(t *testing.T) {
+	a := newAggregator()
+	if err := a.SetPolicy("p1", "temp", model.NewAggregatePolicy(time.Minute, map[model.AggregateFunc]string{model.AggregateLast: ""})); err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now()
+	a.Add("p1", "d1", map[string]interface{}{"temp": 1})
+	after := time.Now()
+
+	// 定时器延迟触发时上报时间仍为窗口结束时间
+	out := a.collect(after.Add(time.Minute + 3*tick))
+	if len(out) != 1 {
+		t.Fatalf("collect() = %v, want one emission", out)
+	}
+	for e := range out {
+		if e.end < before.Add(time.Minute).UnixMilli() || e.end > after.Add(time.Minute).UnixMilli() {
+			t.Fatalf("emission time = %d, want window end in [%d, %d]", e.end, before.Add(time.Minute).UnixMilli(), after.Add(time.Minute).UnixMilli())
+		}
+	}
+}
+
+func TestTumblingWindow(t *testing.T) {
+	a := newAggregator()
+	err := a.SetPolicy("p1", "temp", model.NewAggregatePolicy(time.Minute, map[model.AggregateFunc]string{
+		model.AggregateMin:   "temp_min",
+		model.AggregateMax:   "temp_max",
+		model.AggregateAvg:   "",
+		model.AggregateCount: "temp_count",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []interface{}{1, 5.0, "3"} {
+		rest := a.Add("p1", "d1", map[string]interface{}{"temp": v, "mode": "auto"})
+		if !reflect.DeepEqual(rest, map[string]interface{}{"mode": "auto"}) {
+			t.Fatalf("Add() = %v, want only properties without policy", rest)
+		}
+	}
+
+	if out := a.collect(time.Now()); len(out) != 0 {
+		t.Fatalf("collect() before window end = %v, want nothing", out)
+	}
+	out := a.collect(time.Now().Add(time.Minute))
+	want := map[string]interface{}{"temp_min": 1.0, "temp_max": 5.0, "temp": 3.0, "temp_count": 3}
+	if got := deviceData(out, "d1"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("collect() = %v, want %v", got, want)
+	}
+}
+
+func TestPolicyUpdateAppliesToLiveSeries(t *testing.T) {
+	a := newAggregator()
+	if err := a.SetPolicy("p1", "temp", model.NewAggregatePolicy(time.Minute, map[model.AggregateFunc]string{model.AggregateMin: ""})); err != nil {
+		t.Fatal(err)
+	}
+	a.Add("p1", "d1", map[string]interface{}{"temp": 1})
+	a.Add("p1", "d1", map[string]interface{}{"temp": 9})
+
+	if err := a.SetPolicy("p1", "temp", model.NewAggregatePolicy(time.Minute, map[model.AggregateFunc]string{model.AggregateMax: ""})); err != nil {
+		t.Fatal(err)
+	}
+	out := a.collect(time.Now().Add(time.Minute))
+	if got := deviceData(out, "d1")["temp"]; got != 9.0 {
+		t.Fatalf("collect() temp = %v, want max 9 from the updated policy", got)
+	}
+}
+
+func TestRemovePolicyDropsLiveSeries(t *testing.T) {
+	a := newAggregator()
+	if err := a.SetPolicy("p1", "temp", model.NewAggregatePolicy(time.Minute, map[model.AggregateFunc]string{model.AggregateLast: ""})); err != nil {
+		t.Fatal(err)
+	}
+	a.Add("p1", "d1", map[string]interface{}{"temp": 1})
+	a.RemovePolicy("p1", "temp")
+
+	if out := a.collect(time.Now().Add(time.Minute)); len(out) != 0 {
+		t.Fatalf("collect() after RemovePolicy = %v, want nothing", out)
+	}
+	if rest := a.Add("p1", "d1", map[string]interface{}{"temp": 2}); rest["temp"] != 2 {
+		t.Fatalf("Add() after RemovePolicy = %v, want property passed through", rest)
+	}
+}
+
+func TestSlidingWindowKeepsOverlap(t *testing.T) {
+	a := newAggregator()
+	policy := model.NewAggregatePolicy(2*time.Minute, map[model.AggregateFunc]string{model.AggregateCount: ""})
+	policy.Type = model.WindowSliding
+	policy.Step = time.Minute
+	if err := a.SetPolicy("p1", "temp", policy); err != nil {
+		t.Fatal(err)
+	}
+	a.Add("p1", "d1", map[string]interface{}{"temp": 1})
+	a.Add("p1", "d1", map[string]interface{}{"temp": 2})
+
+	now := time.Now()
+	if got := deviceData(a.collect(now.Add(time.Minute)), "d1")["temp"]; got != 2 {
+		t.Fatalf("first step count = %v, want 2", got)
+	}
+	// 采样点仍在下一个窗口内
+	if got := deviceData(a.collect(now.Add(2*time.Minute-time.Second)), "d1")["temp"]; got != nil {
+		t.Fatalf("collect() before next step = %v, want nothing", got)
+	}
+	if got := deviceData(a.collect(now.Add(2*time.Minute)), "d1")["temp"]; got != 2 {
+		t.Fatalf("second step count = %v, want 2", got)
+	}
+}
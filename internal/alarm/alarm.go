@@ -0,0 +1,282 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package alarm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/internal/cache"
+	"github.com/ytuox/elink-sdk-go/model"
+
+	"github.com/spf13/cast"
+)
+
+// Transition 告警状态变化
+type Transition struct {
+	Rule      model.AlarmRule
+	DeviceId  string
+	Raise     bool
+	Value     interface{}
+	Timestamp int64
+}
+
+type state struct {
+	active  bool
+	pending time.Time
+	value   interface{}
+	since   int64
+}
+
+// Engine 本地阈值告警引擎,同一告警在解除前只触发一次
+type Engine struct {
+	mu              sync.Mutex
+	productProvider cache.ProductProvider
+	rules           map[string]model.AlarmRule
+	states          map[string]map[string]*state
+	now             func() time.Time
+}
+
+func NewEngine(pc cache.ProductProvider) *Engine {
+	return &Engine{
+		productProvider: pc,
+		rules:           make(map[string]model.AlarmRule),
+		states:          make(map[string]map[string]*state),
+		now:             time.Now,
+	}
+}
+
+// SetClock 设置计算持续时间与告警时间戳使用的时钟
+func (e *Engine) SetClock(now func() time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.now = now
+}
+
+func (e *Engine) AddRule(rule model.AlarmRule) error {
+	if rule.Id == "" {
+		return errors.New("required rule id")
+	}
+	if _, ok := e.productProvider.GetPropertySpecByIdentifier(rule.ProductId, rule.Identifier); !ok {
+		return fmt.Errorf("can't find property(%s) spec in product(%s)", rule.Identifier, rule.ProductId)
+	}
+	identifiers := []string{rule.RaiseEvent}
+	if rule.ClearEvent != "" {
+		identifiers = append(identifiers, rule.ClearEvent)
+	}
+	events := make([]model.Event, 0, len(identifiers))
+	for _, identifier := range identifiers {
+		event, ok := e.productProvider.GetEventSpecByIdentifier(rule.ProductId, identifier)
+		if !ok {
+			return fmt.Errorf("can't find event(%s) spec in product(%s)", identifier, rule.ProductId)
+		}
+		events = append(events, event)
+	}
+	if err := checkEventParams(events, rule); err != nil {
+		return err
+	}
+	switch rule.Operator {
+	case model.AlarmGreater, model.AlarmGreaterEqual, model.AlarmLess, model.AlarmLessEqual, model.AlarmEqual, model.AlarmNotEqual:
+	default:
+		return fmt.Errorf("unsupported operator %q", rule.Operator)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules[rule.Id] = rule
+	for _, sm := range e.states {
+		delete(sm, rule.Id)
+	}
+	return nil
+}
+
+func (e *Engine) RemoveRule(id string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.rules, id)
+	for _, sm := range e.states {
+		delete(sm, id)
+	}
+}
+
+func (e *Engine) RemoveById(deviceId string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.states, deviceId)
+}
+
+// Active 返回设备当前处于告警状态的规则
+func (e *Engine) Active(deviceId string) []model.AlarmState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var alarms []model.AlarmState
+	for id, s := range e.states[deviceId] {
+		if !s.active {
+			continue
+		}
+		alarms = append(alarms, model.AlarmState{
+			RuleId:     id,
+			DeviceId:   deviceId,
+			Identifier: e.rules[id].Identifier,
+			Value:      s.value,
+			Timestamp:  s.since,
+		})
+	}
+	return alarms
+}
+
+// Evaluate 根据上报的属性值计算告警状态变化
+func (e *Engine) Evaluate(productId, deviceId string, data map[string]interface{}) []Transition {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var (
+		now         = e.now()
+		transitions []Transition
+	)
+	for id, rule := range e.rules {
+		if rule.ProductId != productId {
+			continue
+		}
+		raw, ok := data[rule.Identifier]
+		if !ok {
+			continue
+		}
+		v, err := cast.ToFloat64E(raw)
+		if err != nil {
+			continue
+		}
+
+		sm, ok := e.states[deviceId]
+		if !ok {
+			sm = make(map[string]*state)
+			e.states[deviceId] = sm
+		}
+		s, ok := sm[id]
+		if !ok {
+			s = &state{}
+			sm[id] = s
+		}
+
+		var hit bool
+		if s.active {
+			hit = !compare(rule.Operator, v, clearThreshold(rule))
+		} else {
+			hit = compare(rule.Operator, v, rule.Threshold)
+		}
+		if !hit {
+			s.pending = time.Time{}
+			continue
+		}
+		if s.pending.IsZero() {
+			s.pending = now
+		}
+		if now.Sub(s.pending) < rule.Duration {
+			continue
+		}
+
+		s.pending = time.Time{}
+		s.active = !s.active
+		s.value = raw
+		if s.active {
+			s.since = now.UnixMilli()
+		}
+		transitions = append(transitions, Transition{
+			Rule:      rule,
+			DeviceId:  deviceId,
+			Raise:     s.active,
+			Value:     raw,
+			Timestamp: now.UnixMilli(),
+		})
+	}
+	return transitions
+}
+
+// Rollback 事件上报失败时恢复告警状态,下次上报属性时重新触发
+func (e *Engine) Rollback(t Transition) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if s, ok := e.states[t.DeviceId][t.Rule.Id]; ok {
+		s.active = !t.Raise
+	}
+}
+
+func clearThreshold(rule model.AlarmRule) float64 {
+	switch rule.Operator {
+	case model.AlarmGreater, model.AlarmGreaterEqual:
+		return rule.Threshold - rule.Hysteresis
+	case model.AlarmLess, model.AlarmLessEqual:
+		return rule.Threshold + rule.Hysteresis
+	}
+	return rule.Threshold
+}
+
+func compare(op model.AlarmOperator, v, threshold float64) bool {
+	switch op {
+	case model.AlarmGreater:
+		return v > threshold
+	case model.AlarmGreaterEqual:
+		return v >= threshold
+	case model.AlarmLess:
+		return v < threshold
+	case model.AlarmLessEqual:
+		return v <= threshold
+	case model.AlarmEqual:
+		return v == threshold
+	case model.AlarmNotEqual:
+		return v != threshold
+	}
+	return false
+}
+
+// checkEventParams 规则携带的参数须为触发或解除事件定义的参数,且覆盖各事件定义的全部参数
+func checkEventParams(events []model.Event, rule model.AlarmRule) error {
+	keys := make(map[string]struct{}, len(rule.Params)+2)
+	for k := range rule.Params {
+		keys[k] = struct{}{}
+	}
+	for _, k := range []string{rule.ValueParam, rule.ThresholdParam} {
+		if k != "" {
+			keys[k] = struct{}{}
+		}
+	}
+
+	defined := make(map[string]struct{})
+	for _, event := range events {
+		var missing []string
+		for _, io := range event.Params {
+			defined[io.Identifier] = struct{}{}
+			if _, ok := keys[io.Identifier]; !ok {
+				missing = append(missing, io.Identifier)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			return fmt.Errorf("params(%s) of event(%s) are not set by the rule", strings.Join(missing, ","), event.Identifier)
+		}
+	}
+	for k := range keys {
+		if _, ok := defined[k]; !ok {
+			return fmt.Errorf("param(%s) is not defined in the events of rule(%s)", k, rule.Id)
+		}
+	}
+	return nil
+}
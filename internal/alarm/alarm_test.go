@@ -0,0 +1,155 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package alarm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/internal/cache"
+	"github.com/ytuox/elink-sdk-go/model"
+)
+
+func newEngine() *Engine {
+	number := model.Define{Type: "float"}
+	return NewEngine(cache.NewProductCache([]model.Product{{
+		Id: "p1",
+		Properties: []model.Property{
+			{ProductId: "p1", Identifier: "temp", Define: number},
+		},
+		Events: []model.Event{
+			{ProductId: "p1", Identifier: "high", Params: []model.InputOutput{
+				{Identifier: "value", Define: number},
+				{Identifier: "limit", Define: number},
+			}},
+			{ProductId: "p1", Identifier: "normal"},
+		},
+	}}))
+}
+
+func highRule() model.AlarmRule {
+	return model.AlarmRule{
+		Id:             "r1",
+		ProductId:      "p1",
+		Identifier:     "temp",
+		Operator:       model.AlarmGreater,
+		Threshold:      80,
+		Hysteresis:     5,
+		RaiseEvent:     "high",
+		ClearEvent:     "normal",
+		ValueParam:     "value",
+		ThresholdParam: "limit",
+	}
+}
+
+func TestAddRuleValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(r *model.AlarmRule)
+	}{
+		{"unknown property", func(r *model.AlarmRule) { r.Identifier = "humidity" }},
+		{"unknown event", func(r *model.AlarmRule) { r.RaiseEvent = "low" }},
+		{"undeclared param", func(r *model.AlarmRule) { r.Params = map[string]interface{}{"threshold": 80} }},
+		{"missing param", func(r *model.AlarmRule) { r.ThresholdParam = "" }},
+		{"param of one event missing", func(r *model.AlarmRule) { r.ValueParam = "" }},
+		{"unsupported operator", func(r *model.AlarmRule) { r.Operator = "~" }},
+	}
+	if err := newEngine().AddRule(highRule()); err != nil {
+		t.Fatalf("AddRule() valid rule error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := highRule()
+			tt.modify(&rule)
+			if err := newEngine().AddRule(rule); err == nil {
+				t.Fatal("AddRule() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestRaiseAndClearWithHysteresis(t *testing.T) {
+	e := newEngine()
+	rule := highRule()
+	rule.ClearEvent = ""
+	if err := e.AddRule(rule); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		value     float64
+		want      int
+		wantRaise bool
+		active    int
+	}{
+		{value: 79, want: 0},
+		{value: 81, want: 1, wantRaise: true, active: 1},
+		{value: 90, want: 0, active: 1}, // 解除前只触发一次
+		{value: 77, want: 0, active: 1}, // 未低于 80-5
+		{value: 74, want: 1, wantRaise: false},
+	}
+	for i, s := range steps {
+		got := e.Evaluate("p1", "d1", map[string]interface{}{"temp": s.value})
+		if len(got) != s.want {
+			t.Fatalf("step %d: Evaluate(%v) = %d transitions, want %d", i, s.value, len(got), s.want)
+		}
+		if s.want > 0 && got[0].Raise != s.wantRaise {
+			t.Fatalf("step %d: Raise = %v, want %v", i, got[0].Raise, s.wantRaise)
+		}
+		if n := len(e.Active("d1")); n != s.active {
+			t.Fatalf("step %d: Active() = %d alarms, want %d", i, n, s.active)
+		}
+	}
+}
+
+func TestDurationDelaysRaise(t *testing.T) {
+	e := newEngine()
+	rule := highRule()
+	rule.Duration = time.Hour
+	if err := e.AddRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if got := e.Evaluate("p1", "d1", map[string]interface{}{"temp": 90}); len(got) != 0 {
+			t.Fatalf("Evaluate() = %v, want no transition before duration elapses", got)
+		}
+	}
+}
+
+func TestRollbackRaisesAgain(t *testing.T) {
+	e := newEngine()
+	if err := e.AddRule(highRule()); err != nil {
+		t.Fatal(err)
+	}
+	got := e.Evaluate("p1", "d1", map[string]interface{}{"temp": 90})
+	if len(got) != 1 {
+		t.Fatalf("Evaluate() = %d transitions, want 1", len(got))
+	}
+	e.Rollback(got[0])
+
+	if again := e.Evaluate("p1", "d1", map[string]interface{}{"temp": 90}); len(again) != 1 || !again[0].Raise {
+		t.Fatalf("Evaluate() after Rollback = %v, want raise again", again)
+	}
+}
+
+func TestEvaluateIgnoresOtherProducts(t *testing.T) {
+	e := newEngine()
+	if err := e.AddRule(highRule()); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.Evaluate("p2", "d1", map[string]interface{}{"temp": 90}); len(got) != 0 {
+		t.Fatalf("Evaluate() = %v, want rule scoped to its product", got)
+	}
+}
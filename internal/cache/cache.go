@@ -27,13 +27,13 @@ import (
 	"github.com/ytuox/elink-sdk-go/model"
 )
 
-func InitDeviceCache(baseMessage common.BaseMessage, cli *client.ResourceClient, logger logger.Logger) (*DeviceCache, error) {
+func InitDeviceCache(baseMessage common.BaseMessage, cli *client.ResourceClient, logger logger.Logger, timeout time.Duration) (*DeviceCache, error) {
 	var (
 		err     error
 		resp    *pb_device.QueryDeviceListResponse
 		devices []model.Device
 	)
-	c, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	c, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 	if resp, err = cli.RPCDeviceClient.QueryDeviceList(c, &pb_device.QueryDeviceListRequest{
 		BaseRequest: baseMessage.BuildBaseRequest(),
@@ -51,13 +51,13 @@ func InitDeviceCache(baseMessage common.BaseMessage, cli *client.ResourceClient,
 	return NewDeviceCache(devices), nil
 }
 
-func InitProductCache(baseMessage common.BaseMessage, cli *client.ResourceClient, logger logger.Logger) (*ProductCache, error) {
+func InitProductCache(baseMessage common.BaseMessage, cli *client.ResourceClient, logger logger.Logger, timeout time.Duration) (*ProductCache, error) {
 	var (
 		err   error
 		ps    []model.Product
 		dpMap = make(map[string]struct{})
 	)
-	c, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	c, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 	resp, err := cli.RPCProductClient.QueryProductList(c, &pb_product.QueryProductListRequest{
 		BaseRequest: baseMessage.BuildBaseRequest(),
@@ -0,0 +1,168 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+const DefaultStorageEntries = 1024
+
+type storageEntry struct {
+	key   string
+	value []byte
+}
+
+// StorageCache 自定义存储的本地缓存,按最近使用淘汰。
+// 缓存包含全部数据(complete)时,未命中的键视为不存在,不再访问核心服务
+type StorageCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List
+	complete   bool
+	gen        uint64
+}
+
+func NewStorageCache(maxEntries int) *StorageCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultStorageEntries
+	}
+	return &StorageCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Load 使用 Invalidate 之后读取的全部数据重建缓存,期间有写入时放弃。
+// 数据超出容量时只缓存部分数据
+func (c *StorageCache) Load(kvs map[string][]byte, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+	for k, v := range kvs {
+		c.set(k, v)
+	}
+	c.complete = len(kvs) <= c.maxEntries
+}
+
+// Invalidate 清空缓存,与核心服务重新连接后数据可能已变化
+func (c *StorageCache) Invalidate() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reset()
+	return c.gen
+}
+
+// Get 返回命中的数据与需要从核心服务读取的键,gen用于 Fill 时判断期间是否有写入
+func (c *StorageCache) Get(keys []string) (hits map[string][]byte, misses []string, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hits = make(map[string][]byte, len(keys))
+	for _, k := range keys {
+		if el, ok := c.entries[k]; ok {
+			c.order.MoveToFront(el)
+			hits[k] = clone(el.Value.(*storageEntry).value)
+		} else if !c.complete {
+			misses = append(misses, k)
+		}
+	}
+	return hits, misses, c.gen
+}
+
+// All 缓存包含全部数据时返回全部数据
+func (c *StorageCache) All() (map[string][]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.complete {
+		return nil, false
+	}
+	kvs := make(map[string][]byte, len(c.entries))
+	for k, el := range c.entries {
+		kvs[k] = clone(el.Value.(*storageEntry).value)
+	}
+	return kvs, true
+}
+
+// Fill 缓存从核心服务读取的数据,读取期间有写入或失效时放弃,避免覆盖更新的数据
+func (c *StorageCache) Fill(kvs map[string][]byte, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+	for k, v := range kvs {
+		c.set(k, v)
+	}
+}
+
+// Put 写入核心服务成功后更新缓存
+func (c *StorageCache) Put(kvs map[string][]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for k, v := range kvs {
+		c.set(k, v)
+	}
+}
+
+// Delete 从核心服务删除成功后更新缓存
+func (c *StorageCache) Delete(keys []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for _, k := range keys {
+		if el, ok := c.entries[k]; ok {
+			c.order.Remove(el)
+			delete(c.entries, k)
+		}
+	}
+}
+
+func (c *StorageCache) set(key string, value []byte) {
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*storageEntry).value = clone(value)
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&storageEntry{key: key, value: clone(value)})
+	for c.order.Len() > c.maxEntries {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.entries, el.Value.(*storageEntry).key)
+		c.complete = false
+	}
+}
+
+func (c *StorageCache) reset() {
+	c.gen++
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+	c.complete = false
+}
+
+func clone(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
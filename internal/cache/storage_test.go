@@ -0,0 +1,113 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStorageCacheHitsAndMisses(t *testing.T) {
+	c := NewStorageCache(10)
+	gen := c.Invalidate()
+	c.Fill(map[string][]byte{"a": []byte("1")}, gen)
+
+	hits, misses, _ := c.Get([]string{"a", "b"})
+	if !reflect.DeepEqual(hits, map[string][]byte{"a": []byte("1")}) || !reflect.DeepEqual(misses, []string{"b"}) {
+		t.Fatalf("Get() = %v, %v, want a hit and b missed", hits, misses)
+	}
+	if _, ok := c.All(); ok {
+		t.Fatal("All() of a partial cache = true")
+	}
+
+	// 缓存包含全部数据时未命中的键视为不存在
+	c.Load(map[string][]byte{"a": []byte("1")}, c.Invalidate())
+	if hits, misses, _ := c.Get([]string{"a", "b"}); len(hits) != 1 || len(misses) != 0 {
+		t.Fatalf("Get() of a complete cache = %v, %v, want no misses", hits, misses)
+	}
+	if kvs, ok := c.All(); !ok || len(kvs) != 1 {
+		t.Fatalf("All() = %v, %v, want the complete data", kvs, ok)
+	}
+
+	// 返回的数据是副本
+	hits["a"][0] = 'x'
+	if hits, _, _ := c.Get([]string{"a"}); string(hits["a"]) != "1" {
+		t.Fatalf("Get() after modifying a result = %s, want 1", hits["a"])
+	}
+}
+
+func TestStorageCacheWrites(t *testing.T) {
+	c := NewStorageCache(10)
+	c.Load(map[string][]byte{"a": []byte("1"), "b": []byte("2")}, c.Invalidate())
+
+	c.Put(map[string][]byte{"a": []byte("3"), "c": []byte("4")})
+	c.Delete([]string{"b"})
+	kvs, ok := c.All()
+	want := map[string][]byte{"a": []byte("3"), "c": []byte("4")}
+	if !ok || !reflect.DeepEqual(kvs, want) {
+		t.Fatalf("All() after writes = %v, %v, want %v", kvs, ok, want)
+	}
+}
+
+func TestStorageCacheDropsStaleReads(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(c *StorageCache)
+	}{
+		{"put", func(c *StorageCache) { c.Put(map[string][]byte{"a": []byte("new")}) }},
+		{"delete", func(c *StorageCache) { c.Delete([]string{"a"}) }},
+		{"invalidate", func(c *StorageCache) { c.Invalidate() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 读取核心服务期间发生写入,读到的旧数据不再写入缓存
+			c := NewStorageCache(10)
+			_, _, gen := c.Get([]string{"a"})
+			tt.write(c)
+			c.Fill(map[string][]byte{"a": []byte("old")}, gen)
+			if hits, _, _ := c.Get([]string{"a"}); string(hits["a"]) == "old" {
+				t.Fatal("Fill() cached data read before a write")
+			}
+
+			gen = c.Invalidate()
+			tt.write(c)
+			c.Load(map[string][]byte{"a": []byte("old")}, gen)
+			if _, ok := c.All(); ok {
+				t.Fatal("Load() completed the cache with data read before a write")
+			}
+		})
+	}
+}
+
+func TestStorageCacheEviction(t *testing.T) {
+	c := NewStorageCache(2)
+	c.Load(map[string][]byte{"a": []byte("1"), "b": []byte("2")}, c.Invalidate())
+	c.Get([]string{"a"})
+
+	// 超出容量时淘汰最久未使用的数据,缓存不再包含全部数据
+	c.Put(map[string][]byte{"c": []byte("3")})
+	hits, misses, _ := c.Get([]string{"a", "b", "c"})
+	if len(hits) != 2 || !reflect.DeepEqual(misses, []string{"b"}) {
+		t.Fatalf("Get() after eviction = %v, %v, want b evicted", hits, misses)
+	}
+	if _, ok := c.All(); ok {
+		t.Fatal("All() after eviction = true")
+	}
+
+	c.Load(map[string][]byte{"a": nil, "b": nil, "c": nil}, c.Invalidate())
+	if _, ok := c.All(); ok {
+		t.Fatal("All() after loading more than the capacity = true")
+	}
+}
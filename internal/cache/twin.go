@@ -0,0 +1,156 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/model"
+)
+
+type TwinProvider interface {
+	Update(deviceId string, ts int64, data map[string]interface{})
+	Get(deviceId, identifier string) (model.PropertyData, bool)
+	All(deviceId string) map[string]model.PropertyData
+	RemoveById(deviceId string)
+	SetPolicy(productId string, policy model.TwinPolicy)
+	RemovePolicy(productId string)
+	Fresh(productId, deviceId string, identifiers []string) ([]model.PropertyGetResponseData, bool)
+}
+
+// TwinCache 设备孪生,保存设备最近一次上报的属性值及时间戳
+type TwinCache struct {
+	mu       sync.RWMutex
+	twinMap  map[string]map[string]model.PropertyData
+	policies map[string]model.TwinPolicy
+	now      func() time.Time
+}
+
+func NewTwinCache() *TwinCache {
+	return &TwinCache{
+		twinMap:  make(map[string]map[string]model.PropertyData),
+		policies: make(map[string]model.TwinPolicy),
+		now:      time.Now,
+	}
+}
+
+// SetClock 设置缺少时间戳的属性值及判断属性值时效使用的时钟
+func (t *TwinCache) SetClock(now func() time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.now = now
+}
+
+func (t *TwinCache) Update(deviceId string, ts int64, data map[string]interface{}) {
+	if len(data) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if ts <= 0 {
+		ts = t.now().UnixMilli()
+	}
+
+	pm, ok := t.twinMap[deviceId]
+	if !ok {
+		pm = make(map[string]model.PropertyData, len(data))
+		t.twinMap[deviceId] = pm
+	}
+	for k, v := range data {
+		// 乱序到达的旧数据不覆盖新数据
+		if old, ok := pm[k]; ok && old.Timestamp > ts {
+			continue
+		}
+		pm[k] = model.PropertyData{Value: v, Timestamp: ts}
+	}
+}
+
+func (t *TwinCache) Get(deviceId, identifier string) (model.PropertyData, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	pm, ok := t.twinMap[deviceId]
+	if !ok {
+		return model.PropertyData{}, false
+	}
+	pd, ok := pm[identifier]
+	return pd, ok
+}
+
+func (t *TwinCache) All(deviceId string) map[string]model.PropertyData {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	pm := t.twinMap[deviceId]
+	data := make(map[string]model.PropertyData, len(pm))
+	for k, v := range pm {
+		data[k] = v
+	}
+	return data
+}
+
+func (t *TwinCache) RemoveById(deviceId string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.twinMap, deviceId)
+}
+
+func (t *TwinCache) SetPolicy(productId string, policy model.TwinPolicy) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.policies[productId] = policy
+}
+
+func (t *TwinCache) RemovePolicy(productId string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.policies, productId)
+}
+
+// Fresh 判断属性值是否都在策略允许的时效内,全部满足时返回可直接应答的数据
+func (t *TwinCache) Fresh(productId, deviceId string, identifiers []string) ([]model.PropertyGetResponseData, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	policy, ok := t.policies[productId]
+	if !ok || len(identifiers) == 0 {
+		return nil, false
+	}
+	pm, ok := t.twinMap[deviceId]
+	if !ok {
+		return nil, false
+	}
+
+	now := t.now().UnixMilli()
+	data := make([]model.PropertyGetResponseData, 0, len(identifiers))
+	for _, id := range identifiers {
+		maxAge := policy.GetMaxAge(id)
+		if maxAge <= 0 {
+			return nil, false
+		}
+		pd, ok := pm[id]
+		if !ok || now-pd.Timestamp > maxAge.Milliseconds() {
+			return nil, false
+		}
+		data = append(data, model.PropertyGetResponseData{
+			Identifier: id,
+			Value:      pd.Value,
+			Timestamp:  pd.Timestamp,
+		})
+	}
+	return data, true
+}
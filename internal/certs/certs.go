@@ -0,0 +1,206 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+// Package certs 加载TLS证书,证书文件修改后在下一次握手时重新加载
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/internal/logger"
+)
+
+// checkInterval 检查证书文件是否修改的最小间隔
+const checkInterval = 5 * time.Second
+
+// Files 证书文件,CertFile与KeyFile需同时设置,CAFile为空时使用系统根证书
+type Files struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+}
+
+// Reloader 保存当前使用的证书,重新加载失败时继续使用原证书
+type Reloader struct {
+	files  Files
+	logger logger.Logger
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+}
+
+func NewReloader(files Files, l logger.Logger) (*Reloader, error) {
+	if (files.CertFile == "") != (files.KeyFile == "") {
+		return nil, errors.New("certFile and keyFile must be set together")
+	}
+	r := &Reloader{files: files, logger: l}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ServerConfig 服务端配置,clientAuth为true时要求并校验客户端证书
+func (r *Reloader) ServerConfig(clientAuth bool) (*tls.Config, error) {
+	if r.files.CertFile == "" {
+		return nil, errors.New("server requires certFile and keyFile")
+	}
+	if clientAuth && r.files.CAFile == "" {
+		return nil, errors.New("client auth requires caFile")
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := r.current()
+			c := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				ClientCAs:    pool,
+			}
+			if clientAuth {
+				c.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return c, nil
+		},
+	}, nil
+}
+
+// ClientConfig 客户端配置,设置了证书时用于双向认证,serverName不为空时覆盖校验的服务端名称
+func (r *Reloader) ClientConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		// 根证书可能被重新加载,由VerifyConnection使用当前的根证书校验
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			return r.verify(cs)
+		},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			if cert == nil {
+				return &tls.Certificate{}, nil
+			}
+			return cert, nil
+		},
+	}
+}
+
+func (r *Reloader) verify(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("no server certificate")
+	}
+	_, pool := r.current()
+	opts := x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         pool,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, c := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// current 返回当前证书,距上次检查超过checkInterval且文件已修改时重新加载
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.RLock()
+	check := time.Since(r.lastCheck) >= checkInterval
+	cert, pool := r.cert, r.pool
+	r.mu.RUnlock()
+	if !check {
+		return cert, pool
+	}
+
+	r.mu.Lock()
+	r.lastCheck = time.Now()
+	r.mu.Unlock()
+	if r.modified() {
+		if err := r.load(); err != nil {
+			r.logger.Errorf("reload certificates error: %s", err)
+		} else {
+			r.logger.Infof("certificates reloaded")
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, r.pool
+}
+
+func (r *Reloader) modified() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for file, t := range r.modTimes {
+		info, err := os.Stat(file)
+		if err == nil && !info.ModTime().Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reloader) load() error {
+	modTimes := make(map[string]time.Time)
+	for _, file := range []string{r.files.CertFile, r.files.KeyFile, r.files.CAFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	var cert *tls.Certificate
+	if r.files.CertFile != "" {
+		c, err := tls.LoadX509KeyPair(r.files.CertFile, r.files.KeyFile)
+		if err != nil {
+			return fmt.Errorf("load key pair: %w", err)
+		}
+		cert = &c
+	}
+
+	var pool *x509.CertPool
+	if r.files.CAFile != "" {
+		pem, err := os.ReadFile(r.files.CAFile)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", r.files.CAFile)
+		}
+	} else {
+		var err error
+		if pool, err = x509.SystemCertPool(); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert, r.pool, r.modTimes = cert, pool, modTimes
+	return nil
+}
@@ -0,0 +1,282 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/internal/logger"
+)
+
+type keyPair struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// issue 生成证书,parent为nil时生成自签名CA
+func issue(t *testing.T, name string, serial int64, parent *keyPair) *keyPair {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	signer := &keyPair{cert: tmpl, key: key}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		tmpl.DNSNames = []string{name}
+		tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+		signer = parent
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer.cert, &key.PublicKey, signer.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &keyPair{cert: cert, key: key}
+}
+
+// write 写入证书与私钥文件,并将修改时间设置为mtime
+func write(t *testing.T, dir, name string, kp *keyPair, mtime time.Time) Files {
+	t.Helper()
+	files := Files{
+		CertFile: filepath.Join(dir, name+".crt"),
+		KeyFile:  filepath.Join(dir, name+".key"),
+	}
+	der, err := x509.MarshalECPrivateKey(kp.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, files.CertFile, "CERTIFICATE", kp.cert.Raw, mtime)
+	writePEM(t, files.KeyFile, "EC PRIVATE KEY", der, mtime)
+	return files
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newReloader(t *testing.T, files Files) *Reloader {
+	t.Helper()
+	r, err := NewReloader(files, logger.NewLogger("", "error", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// handshake 通过本地TCP连接完成握手,返回客户端看到的服务端证书
+func handshake(server, client *tls.Config) (*x509.Certificate, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	defer ln.Close()
+	errc := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			errc <- err
+			return
+		}
+		defer conn.Close()
+		errc <- tls.Server(conn, server).Handshake()
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	c := tls.Client(conn, client)
+	err = c.Handshake()
+	// TLS 1.3中服务端在客户端握手完成后才校验客户端证书,以服务端结果为准
+	if serr := <-errc; serr != nil {
+		return nil, serr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.ConnectionState().PeerCertificates[0], nil
+}
+
+type env struct {
+	dir        string
+	ca         *keyPair
+	caFile     string
+	serverFile Files
+	clientFile Files
+}
+
+func newEnv(t *testing.T) *env {
+	dir := t.TempDir()
+	ca := issue(t, "test-ca", 1, nil)
+	e := &env{
+		dir:    dir,
+		ca:     ca,
+		caFile: filepath.Join(dir, "ca.crt"),
+	}
+	now := time.Now()
+	writePEM(t, e.caFile, "CERTIFICATE", ca.cert.Raw, now)
+	e.serverFile = write(t, dir, "server", issue(t, "localhost", 2, ca), now)
+	e.clientFile = write(t, dir, "client", issue(t, "client", 3, ca), now)
+	e.serverFile.CAFile = e.caFile
+	e.clientFile.CAFile = e.caFile
+	return e
+}
+
+func TestHandshake(t *testing.T) {
+	e := newEnv(t)
+	server, err := newReloader(t, e.serverFile).ServerConfig(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newReloader(t, Files{CAFile: e.caFile})
+
+	cert, err := handshake(server, client.ClientConfig("localhost"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != "localhost" {
+		t.Fatalf("server certificate = %s, want localhost", cert.Subject.CommonName)
+	}
+
+	// 不信任的CA签发的服务端证书
+	other := newReloader(t, Files{CAFile: writeCA(t, issue(t, "other-ca", 9, nil))})
+	if _, err = handshake(server, other.ClientConfig("localhost")); err == nil {
+		t.Fatal("handshake with an untrusted server certificate succeeded")
+	}
+}
+
+func writeCA(t *testing.T, ca *keyPair) string {
+	path := filepath.Join(t.TempDir(), "ca.crt")
+	writePEM(t, path, "CERTIFICATE", ca.cert.Raw, time.Now())
+	return path
+}
+
+func TestMutualTLS(t *testing.T) {
+	e := newEnv(t)
+	server, err := newReloader(t, e.serverFile).ServerConfig(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	anonymous := newReloader(t, Files{CAFile: e.caFile})
+	if _, err = handshake(server, anonymous.ClientConfig("localhost")); err == nil {
+		t.Fatal("handshake without client certificate succeeded")
+	}
+
+	client := newReloader(t, e.clientFile)
+	if _, err = handshake(server, client.ClientConfig("localhost")); err != nil {
+		t.Fatalf("handshake with client certificate: %s", err)
+	}
+}
+
+func TestServerNameOverride(t *testing.T) {
+	e := newEnv(t)
+	server, err := newReloader(t, e.serverFile).ServerConfig(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newReloader(t, Files{CAFile: e.caFile})
+
+	if _, err = handshake(server, client.ClientConfig("plugin.example.com")); err == nil {
+		t.Fatal("handshake with mismatched server name succeeded")
+	}
+	if _, err = handshake(server, client.ClientConfig("localhost")); err != nil {
+		t.Fatalf("handshake with overridden server name: %s", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	e := newEnv(t)
+	serverReloader := newReloader(t, e.serverFile)
+	server, err := serverReloader.ServerConfig(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newReloader(t, Files{CAFile: e.caFile})
+
+	// 替换为新CA签发的证书,客户端仍信任旧CA
+	later := time.Now().Add(time.Minute)
+	ca := issue(t, "rotated-ca", 10, nil)
+	write(t, e.dir, "server", issue(t, "localhost", 11, ca), later)
+	serverReloader.lastCheck = time.Time{}
+	if _, err = handshake(server, client.ClientConfig("localhost")); err == nil {
+		t.Fatal("handshake succeeded before the client reloaded its CA")
+	}
+
+	writePEM(t, e.caFile, "CERTIFICATE", ca.cert.Raw, later)
+	client.lastCheck = time.Time{}
+	cert, err := handshake(server, client.ClientConfig("localhost"))
+	if err != nil {
+		t.Fatalf("handshake after reload: %s", err)
+	}
+	if cert.SerialNumber.Int64() != 11 {
+		t.Fatalf("server certificate serial = %s, want 11", cert.SerialNumber)
+	}
+
+	// 文件损坏时继续使用原证书
+	if err = os.WriteFile(e.serverFile.CertFile, []byte("broken"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	serverReloader.lastCheck = time.Time{}
+	if _, err = handshake(server, client.ClientConfig("localhost")); err != nil {
+		t.Fatalf("handshake after failed reload: %s", err)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	e := newEnv(t)
+	if _, err := NewReloader(Files{CertFile: e.serverFile.CertFile}, nil); err == nil {
+		t.Error("NewReloader() accepted certFile without keyFile")
+	}
+	if _, err := newReloader(t, Files{CAFile: e.caFile}).ServerConfig(false); err == nil {
+		t.Error("ServerConfig() accepted a reloader without certificate")
+	}
+	if _, err := newReloader(t, Files{CertFile: e.serverFile.CertFile, KeyFile: e.serverFile.KeyFile}).ServerConfig(true); err == nil {
+		t.Error("ServerConfig(true) accepted a reloader without caFile")
+	}
+}
@@ -19,9 +19,14 @@ import (
 	"errors"
 	"time"
 
+	"github.com/ytuox/elink-sdk-go/internal/certs"
 	"github.com/ytuox/elink-sdk-go/internal/config"
+	"github.com/ytuox/elink-sdk-go/internal/logger"
+	"github.com/ytuox/elink-sdk-go/model"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/keepalive"
 
@@ -35,6 +40,7 @@ import (
 
 type ResourceClient struct {
 	address string
+	guard   *guard
 	Conn    *grpc.ClientConn
 	pb_common.CommonClient
 	pb_device.RPCDeviceClient
@@ -61,31 +67,44 @@ var keep = keepalive.ClientParameters{
 	PermitWithoutStream: true,
 }
 
-func dial(address string) (*grpc.ClientConn, error) {
+func dial(address string, creds credentials.TransportCredentials, timeout time.Duration, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	conn, err := grpc.DialContext(ctx, address, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock(), grpc.WithKeepaliveParams(keep), grpc.WithConnectParams(connParams))
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(creds), grpc.WithBlock(), grpc.WithKeepaliveParams(keep), grpc.WithConnectParams(connParams)}, opts...)
+	conn, err := grpc.DialContext(ctx, address, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return conn, nil
 }
 
-func NewCoreClient(cfg config.AdapterRPC) (*ResourceClient, error) {
+// NewCoreClient 连接核心服务,opts追加在默认连接参数之后
+func NewCoreClient(cfg config.AdapterRPC, l logger.Logger, timeout time.Duration, opts ...grpc.DialOption) (*ResourceClient, error) {
 
 	if cfg.Address == "" {
 		return nil, errors.New("required address")
 	}
 
-	conn, err := dial(cfg.Address)
+	creds := insecure.NewCredentials()
+	if cfg.UseTLS {
+		r, err := certs.NewReloader(certs.Files{CertFile: cfg.CertFile, KeyFile: cfg.KeyFile, CAFile: cfg.CAFile}, l)
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(r.ClientConfig(cfg.ServerName))
+	}
+
+	g := newGuard(l)
+	conn, err := dial(cfg.Address, creds, timeout, append(opts, grpc.WithChainUnaryInterceptor(g.intercept))...)
 	if err != nil {
 		return nil, err
 	}
 
 	rc := &ResourceClient{
 		address:             cfg.Address,
+		guard:               g,
 		Conn:                conn,
 		CommonClient:        pb_common.NewCommonClient(conn),
 		StorageClient:       pb_storage.NewStorageClient(conn),
@@ -97,6 +116,34 @@ func NewCoreClient(cfg config.AdapterRPC) (*ResourceClient, error) {
 	return rc, nil
 }
 
+// SetRetryPolicy 设置调用失败时的重试策略
+func (c *ResourceClient) SetRetryPolicy(policy model.RetryPolicy) {
+	c.guard.setRetryPolicy(policy)
+}
+
+// SetCircuitBreaker 设置熔断策略
+func (c *ResourceClient) SetCircuitBreaker(policy model.CircuitBreakerPolicy) {
+	c.guard.setBreakerPolicy(policy)
+}
+
+func (c *ResourceClient) CircuitState() model.CircuitState {
+	return c.guard.circuitState()
+}
+
+// WatchReconnect 连接断开后重新就绪时调用fn
+func (c *ResourceClient) WatchReconnect(ctx context.Context, fn func()) {
+	go func() {
+		state := c.Conn.GetState()
+		for c.Conn.WaitForStateChange(ctx, state) {
+			last := state
+			state = c.Conn.GetState()
+			if state == connectivity.Ready && last != connectivity.Ready {
+				fn()
+			}
+		}
+	}()
+}
+
 func (c *ResourceClient) Close() error {
 	return c.Conn.Close()
 }
@@ -0,0 +1,195 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package client
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/internal/logger"
+	"github.com/ytuox/elink-sdk-go/model"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// guard 调用核心服务的重试与熔断
+type guard struct {
+	mu       sync.Mutex
+	logger   logger.Logger
+	retry    *model.RetryPolicy
+	breaker  *model.CircuitBreakerPolicy
+	state    model.CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newGuard(l logger.Logger) *guard {
+	return &guard{
+		logger: l,
+		state:  model.CircuitClosed,
+	}
+}
+
+func (g *guard) setRetryPolicy(policy model.RetryPolicy) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(policy.Codes) == 0 {
+		policy.Codes = []codes.Code{codes.Unavailable}
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = 1
+	}
+	g.retry = &policy
+}
+
+func (g *guard) setBreakerPolicy(policy model.CircuitBreakerPolicy) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.breaker = &policy
+	g.failures = 0
+	g.setState(model.CircuitClosed)
+}
+
+func (g *guard) circuitState() model.CircuitState {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.state
+}
+
+func (g *guard) intercept(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	g.mu.Lock()
+	retry := g.retry
+	g.mu.Unlock()
+
+	attempts := 1
+	if retry != nil && retry.MaxAttempts > 1 {
+		attempts = retry.MaxAttempts
+	}
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			timer := time.NewTimer(retryDelay(*retry, i))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+		}
+		if !g.allow() {
+			return status.Errorf(codes.Unavailable, "circuit breaker is open")
+		}
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		g.done(err)
+		if err == nil || retry == nil || !retryable(*retry, err) {
+			return err
+		}
+		g.logger.Warnf("call %s error: %s, attempt %d/%d", method, err, i+1, attempts)
+	}
+	return err
+}
+
+// allow 熔断时拒绝调用,熔断到期后只放行一次探测调用
+func (g *guard) allow() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.breaker == nil {
+		return true
+	}
+	switch g.state {
+	case model.CircuitOpen:
+		if time.Since(g.openedAt) < g.breaker.OpenTimeout {
+			return false
+		}
+		g.setState(model.CircuitHalfOpen)
+		g.probing = true
+		return true
+	case model.CircuitHalfOpen:
+		if g.probing {
+			return false
+		}
+		g.probing = true
+	}
+	return true
+}
+
+func (g *guard) done(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.breaker == nil {
+		return
+	}
+	g.probing = false
+	if !unavailable(err) {
+		g.failures = 0
+		if g.state != model.CircuitClosed {
+			g.setState(model.CircuitClosed)
+		}
+		return
+	}
+	g.failures++
+	if g.state == model.CircuitHalfOpen || (g.breaker.FailureThreshold > 0 && g.failures >= g.breaker.FailureThreshold) {
+		g.openedAt = time.Now()
+		if g.state != model.CircuitOpen {
+			g.setState(model.CircuitOpen)
+		}
+	}
+}
+
+func (g *guard) setState(state model.CircuitState) {
+	if g.state != state {
+		g.logger.Warnf("core client circuit breaker %s -> %s", g.state, state)
+	}
+	g.state = state
+}
+
+func retryDelay(policy model.RetryPolicy, retries int) time.Duration {
+	delay := float64(policy.BaseDelay) * math.Pow(policy.Multiplier, float64(retries-1))
+	if policy.MaxDelay > 0 {
+		delay = math.Min(delay, float64(policy.MaxDelay))
+	}
+	if policy.Jitter > 0 {
+		delay *= 1 + policy.Jitter*(rand.Float64()*2-1)
+	}
+	return time.Duration(delay)
+}
+
+func retryable(policy model.RetryPolicy, err error) bool {
+	code := status.Code(err)
+	for _, c := range policy.Codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// unavailable 核心服务不可用的错误计入熔断
+func unavailable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/internal/logger"
+	"github.com/ytuox/elink-sdk-go/model"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeInvoker 按顺序返回预设的错误,用完后返回最后一个
+type fakeInvoker struct {
+	errs   []error
+	calls  int
+	during func()
+}
+
+func (f *fakeInvoker) invoke(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+	f.calls++
+	if f.during != nil {
+		f.during()
+	}
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	if len(f.errs) > 1 {
+		f.errs = f.errs[1:]
+	}
+	return err
+}
+
+func (f *fakeInvoker) call(g *guard) error {
+	return g.intercept(context.Background(), "/test", nil, nil, nil, f.invoke)
+}
+
+func newTestGuard() *guard {
+	return newGuard(logger.NewLogger("", "error", "test"))
+}
+
+var errUnavailable = status.Error(codes.Unavailable, "core down")
+
+func TestRetryAttempts(t *testing.T) {
+	invalid := status.Error(codes.InvalidArgument, "bad request")
+	exhausted := status.Error(codes.ResourceExhausted, "busy")
+	tests := []struct {
+		name      string
+		policy    *model.RetryPolicy
+		errs      []error
+		wantCalls int
+		wantCode  codes.Code
+	}{
+		{"no policy", nil, []error{errUnavailable}, 1, codes.Unavailable},
+		{"gives up after max attempts", &model.RetryPolicy{MaxAttempts: 3}, []error{errUnavailable}, 3, codes.Unavailable},
+		{"succeeds on retry", &model.RetryPolicy{MaxAttempts: 3}, []error{errUnavailable, nil}, 2, codes.OK},
+		{"not retryable", &model.RetryPolicy{MaxAttempts: 3}, []error{invalid}, 1, codes.InvalidArgument},
+		{"custom codes", &model.RetryPolicy{MaxAttempts: 3, Codes: []codes.Code{codes.ResourceExhausted}}, []error{exhausted, errUnavailable}, 2, codes.Unavailable},
+		{"single attempt", &model.RetryPolicy{MaxAttempts: 1}, []error{errUnavailable}, 1, codes.Unavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGuard()
+			if tt.policy != nil {
+				tt.policy.BaseDelay = time.Millisecond
+				g.setRetryPolicy(*tt.policy)
+			}
+			f := &fakeInvoker{errs: tt.errs}
+			err := f.call(g)
+			if status.Code(err) != tt.wantCode || f.calls != tt.wantCalls {
+				t.Fatalf("intercept() = %v after %d calls, want %s after %d", err, f.calls, tt.wantCode, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryStopsOnContextDone(t *testing.T) {
+	g := newTestGuard()
+	g.setRetryPolicy(model.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Minute})
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &fakeInvoker{errs: []error{errUnavailable}, during: cancel}
+
+	err := g.intercept(ctx, "/test", nil, nil, nil, f.invoke)
+	if status.Code(err) != codes.Unavailable || f.calls != 1 {
+		t.Fatalf("intercept() = %v after %d calls, want the last error without retrying", err, f.calls)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := model.RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond, Multiplier: 2}
+	for retries, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 300 * time.Millisecond,
+		4: 300 * time.Millisecond,
+	} {
+		if got := retryDelay(policy, retries); got != want {
+			t.Fatalf("retryDelay(%d) = %s, want %s", retries, got, want)
+		}
+	}
+
+	policy.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		if got := retryDelay(policy, 1); got < 80*time.Millisecond || got > 120*time.Millisecond {
+			t.Fatalf("retryDelay() with jitter = %s, want within 20%% of 100ms", got)
+		}
+	}
+}
+
+func TestBreakerStates(t *testing.T) {
+	const openTimeout = 50 * time.Millisecond
+	g := newTestGuard()
+	g.setBreakerPolicy(model.CircuitBreakerPolicy{FailureThreshold: 2, OpenTimeout: openTimeout})
+	f := &fakeInvoker{errs: []error{errUnavailable}}
+
+	// 不计入熔断的错误清零连续失败次数
+	f.call(g)
+	(&fakeInvoker{errs: []error{status.Error(codes.NotFound, "")}}).call(g)
+	f.call(g)
+	if state := g.circuitState(); state != model.CircuitClosed {
+		t.Fatalf("state after non-consecutive failures = %s, want closed", state)
+	}
+	f.call(g)
+	if state := g.circuitState(); state != model.CircuitOpen {
+		t.Fatalf("state after 2 consecutive failures = %s, want open", state)
+	}
+
+	// 熔断期间快速失败,不调用核心服务
+	calls := f.calls
+	if err := f.call(g); status.Code(err) != codes.Unavailable || status.Convert(err).Message() != "circuit breaker is open" {
+		t.Fatalf("intercept() while open = %v, want circuit breaker is open", err)
+	}
+	if f.calls != calls {
+		t.Fatal("intercept() called the core while the breaker is open")
+	}
+
+	// 到期后放行一次探测调用,探测期间其他调用被拒绝,探测失败重新熔断
+	time.Sleep(openTimeout + 10*time.Millisecond)
+	f.during = func() {
+		if state := g.circuitState(); state != model.CircuitHalfOpen {
+			t.Errorf("state during probe = %s, want half-open", state)
+		}
+		if g.allow() {
+			t.Error("allow() during probe = true, want a single probe")
+		}
+	}
+	f.call(g)
+	if state := g.circuitState(); state != model.CircuitOpen || f.calls != calls+1 {
+		t.Fatalf("state after failed probe = %s with %d calls, want open after one probe", state, f.calls-calls)
+	}
+
+	// 探测成功后恢复
+	time.Sleep(openTimeout + 10*time.Millisecond)
+	f.errs = []error{nil}
+	if err := f.call(g); err != nil {
+		t.Fatal(err)
+	}
+	f.during = nil
+	if state := g.circuitState(); state != model.CircuitClosed {
+		t.Fatalf("state after successful probe = %s, want closed", state)
+	}
+	f.errs = []error{errUnavailable}
+	f.call(g)
+	if state := g.circuitState(); state != model.CircuitClosed {
+		t.Fatalf("state after one failure = %s, want failures reset on recovery", state)
+	}
+}
+
+func TestRetryStopsWhenBreakerOpens(t *testing.T) {
+	g := newTestGuard()
+	g.setRetryPolicy(model.RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond})
+	g.setBreakerPolicy(model.CircuitBreakerPolicy{FailureThreshold: 2, OpenTimeout: time.Minute})
+	f := &fakeInvoker{errs: []error{errUnavailable}}
+
+	err := f.call(g)
+	if status.Code(err) != codes.Unavailable || f.calls != 2 {
+		t.Fatalf("intercept() = %v after %d calls, want breaker to stop retries after 2", err, f.calls)
+	}
+	if state := g.circuitState(); state != model.CircuitOpen {
+		t.Fatalf("state = %s, want open", state)
+	}
+}
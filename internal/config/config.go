@@ -15,18 +15,25 @@
 package config
 
 type (
+	// PluginRPC 插件服务配置,ClientAuth为true时使用CAFile校验核心服务的客户端证书
 	PluginRPC struct {
-		Address  string
-		UseTLS   bool
-		CertFile string
-		KeyFile  string
+		Address    string
+		UseTLS     bool
+		CertFile   string
+		KeyFile    string
+		CAFile     string
+		ClientAuth bool
 	}
 
+	// AdapterRPC 核心服务连接配置,设置CertFile与KeyFile时使用双向认证,
+	// CAFile为空时使用系统根证书,ServerName不为空时覆盖校验的服务端名称
 	AdapterRPC struct {
-		Address  string
-		UseTLS   bool
-		CertFile string
-		KeyFile  string
+		Address    string
+		UseTLS     bool
+		CertFile   string
+		KeyFile    string
+		CAFile     string
+		ServerName string
 	}
 
 	LogConfig struct {
@@ -34,6 +41,12 @@ type (
 		Level string
 	}
 
+	// StorageConfig 自定义存储加密配置,密钥轮换时将旧密钥移入OldSecrets
+	StorageConfig struct {
+		Secret     string
+		OldSecrets []string
+	}
+
 	AdapterCfg struct {
 		Connect     string
 		AdapterId   string
@@ -41,6 +54,7 @@ type (
 		PluginRPC   PluginRPC
 		PluginParam string
 		Logger      LogConfig
+		Storage     StorageConfig
 	}
 )
 
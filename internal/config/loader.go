@@ -0,0 +1,238 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+const EnvPrefix = "ELINK"
+
+// Source 配置来源,优先级从低到高依次为 JSON、File、环境变量、Args
+type Source struct {
+	JSON string   // JSON格式的配置
+	File string   // yaml、json或toml格式的配置文件,Args中的 -config 参数可以指定该文件
+	Args []string // 命令行参数,如 -adapter-rpc-address 127.0.0.1:57081
+}
+
+// field 可以通过环境变量与命令行参数设置的配置项,
+// 环境变量名为 ELINK_ 加上大写的参数名,如 ELINK_ADAPTER_RPC_ADDRESS
+type field struct {
+	name    string
+	usage   string
+	boolean bool
+	set     func(c *AdapterCfg, v string) error
+}
+
+func setString(p func(c *AdapterCfg) *string) func(c *AdapterCfg, v string) error {
+	return func(c *AdapterCfg, v string) error {
+		*p(c) = v
+		return nil
+	}
+}
+
+func setBool(p func(c *AdapterCfg) *bool) func(c *AdapterCfg, v string) error {
+	return func(c *AdapterCfg, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*p(c) = b
+		return nil
+	}
+}
+
+var fields = []field{
+	{name: "connect", usage: "connect info", set: setString(func(c *AdapterCfg) *string { return &c.Connect })},
+	{name: "adapter-id", usage: "plugin id", set: setString(func(c *AdapterCfg) *string { return &c.AdapterId })},
+	{name: "adapter-rpc-address", usage: "core rpc address", set: setString(func(c *AdapterCfg) *string { return &c.AdapterRPC.Address })},
+	{name: "adapter-rpc-use-tls", usage: "connect core rpc with tls", boolean: true, set: setBool(func(c *AdapterCfg) *bool { return &c.AdapterRPC.UseTLS })},
+	{name: "adapter-rpc-cert-file", usage: "core rpc client cert file", set: setString(func(c *AdapterCfg) *string { return &c.AdapterRPC.CertFile })},
+	{name: "adapter-rpc-key-file", usage: "core rpc client key file", set: setString(func(c *AdapterCfg) *string { return &c.AdapterRPC.KeyFile })},
+	{name: "adapter-rpc-ca-file", usage: "core rpc ca file", set: setString(func(c *AdapterCfg) *string { return &c.AdapterRPC.CAFile })},
+	{name: "adapter-rpc-server-name", usage: "core rpc server name override", set: setString(func(c *AdapterCfg) *string { return &c.AdapterRPC.ServerName })},
+	{name: "plugin-rpc-address", usage: "plugin rpc listen address", set: setString(func(c *AdapterCfg) *string { return &c.PluginRPC.Address })},
+	{name: "plugin-rpc-use-tls", usage: "serve plugin rpc with tls", boolean: true, set: setBool(func(c *AdapterCfg) *bool { return &c.PluginRPC.UseTLS })},
+	{name: "plugin-rpc-cert-file", usage: "plugin rpc server cert file", set: setString(func(c *AdapterCfg) *string { return &c.PluginRPC.CertFile })},
+	{name: "plugin-rpc-key-file", usage: "plugin rpc server key file", set: setString(func(c *AdapterCfg) *string { return &c.PluginRPC.KeyFile })},
+	{name: "plugin-rpc-ca-file", usage: "plugin rpc client ca file", set: setString(func(c *AdapterCfg) *string { return &c.PluginRPC.CAFile })},
+	{name: "plugin-rpc-client-auth", usage: "require and verify client cert", boolean: true, set: setBool(func(c *AdapterCfg) *bool { return &c.PluginRPC.ClientAuth })},
+	{name: "plugin-param", usage: "plugin params in json", set: setString(func(c *AdapterCfg) *string { return &c.PluginParam })},
+	{name: "logger-path", usage: "log file path", set: setString(func(c *AdapterCfg) *string { return &c.Logger.Path })},
+	{name: "logger-level", usage: "log level", set: setString(func(c *AdapterCfg) *string { return &c.Logger.Level })},
+	{name: "storage-secret", usage: "custom storage secret", set: setString(func(c *AdapterCfg) *string { return &c.Storage.Secret })},
+	{name: "storage-old-secrets", usage: "comma separated old custom storage secrets", set: func(c *AdapterCfg, v string) error {
+		c.Storage.OldSecrets = strings.Split(v, ",")
+		return nil
+	}},
+}
+
+// ConfigFile 返回实际使用的配置文件,Args中的 -config 参数优先
+func (s Source) ConfigFile() string {
+	if file, _, err := parseFlags(s.Args); err == nil && file != "" {
+		return file
+	}
+	return s.File
+}
+
+func envName(name string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// Load 按优先级合并各来源的配置并校验,返回全部错误
+func Load(src Source) (AdapterCfg, error) {
+	var cfg AdapterCfg
+
+	file, flags, err := parseFlags(src.Args)
+	if err != nil {
+		return cfg, err
+	}
+	if src.JSON != "" {
+		if err = json.Unmarshal([]byte(src.JSON), &cfg); err != nil {
+			return cfg, fmt.Errorf("decode config: %w", err)
+		}
+	}
+	if file == "" {
+		file = src.File
+	}
+	if file != "" {
+		if err = LoadFile(file, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+
+	var errs []error
+	for _, f := range fields {
+		if v, ok := os.LookupEnv(envName(f.name)); ok {
+			if err = f.set(&cfg, v); err != nil {
+				errs = append(errs, fmt.Errorf("env %s: %w", envName(f.name), err))
+			}
+		}
+	}
+	for _, apply := range flags {
+		apply(&cfg)
+	}
+	if err = cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// parseFlags 解析命令行参数,返回 -config 指定的配置文件及其余参数的设置函数
+func parseFlags(args []string) (string, []func(c *AdapterCfg), error) {
+	var (
+		file  string
+		flags []func(c *AdapterCfg)
+	)
+	if len(args) == 0 {
+		return file, flags, nil
+	}
+	fs := flag.NewFlagSet("elink", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.StringVar(&file, "config", "", "config file (yaml, json or toml)")
+	for _, f := range fields {
+		f := f
+		parse := func(v string) error {
+			// 先检查取值,合并配置时再设置
+			if err := f.set(&AdapterCfg{}, v); err != nil {
+				return err
+			}
+			flags = append(flags, func(c *AdapterCfg) { _ = f.set(c, v) })
+			return nil
+		}
+		if f.boolean {
+			fs.BoolFunc(f.name, f.usage, parse)
+		} else {
+			fs.Func(f.name, f.usage, parse)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return "", nil, err
+	}
+	return file, flags, nil
+}
+
+// LoadFile 读取配置文件,按扩展名识别 yaml、json 与 toml 格式。
+// pluginParam 可以直接写为对象,读取后转换为JSON字符串
+func LoadFile(path string, cfg *AdapterCfg) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	var m map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		var raw map[interface{}]interface{}
+		if err = yaml.Unmarshal(data, &raw); err == nil {
+			m, _ = normalize(raw).(map[string]interface{})
+		}
+	case ".json":
+		err = json.Unmarshal(data, &m)
+	case ".toml":
+		err = toml.Unmarshal(data, &m)
+	default:
+		return fmt.Errorf("unsupported config file format: %s", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("decode config file %s: %w", path, err)
+	}
+
+	for k, v := range m {
+		if _, ok := v.(string); ok || !strings.EqualFold(k, "pluginParam") {
+			continue
+		}
+		param, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("encode pluginParam: %w", err)
+		}
+		m[k] = string(param)
+	}
+	data, err = json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("decode config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// normalize 将yaml解析出的 map[interface{}]interface{} 转换为可以JSON编码的类型
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[fmt.Sprint(k)] = normalize(v)
+		}
+		return m
+	case []interface{}:
+		for i := range t {
+			t[i] = normalize(t[i])
+		}
+	}
+	return v
+}
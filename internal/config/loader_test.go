@@ -0,0 +1,166 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const baseJSON = `{"adapterId":"json","adapterRPC":{"address":"127.0.0.1:9000"},"pluginRPC":{"address":"127.0.0.1:9001"},"logger":{"path":"plugin.log"}}`
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "plugin.yaml", "adapterId: file\nlogger:\n  level: warn\n")
+	t.Setenv("ELINK_ADAPTER_ID", "env")
+	logPath := filepath.Join(t.TempDir(), "env.log")
+	t.Setenv("ELINK_LOGGER_PATH", logPath)
+
+	cfg, err := Load(Source{JSON: baseJSON, File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AdapterId != "env" || cfg.Logger.Path != logPath || cfg.Logger.Level != "warn" || cfg.AdapterRPC.Address != "127.0.0.1:9000" {
+		t.Fatalf("Load() = %+v, want env over file over JSON", cfg)
+	}
+
+	cfg, err = Load(Source{JSON: baseJSON, File: file, Args: []string{"-adapter-id", "flag", "-plugin-rpc-use-tls=false"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AdapterId != "flag" {
+		t.Fatalf("AdapterId = %s, want flag over env", cfg.AdapterId)
+	}
+}
+
+func TestLoadFileFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"plugin.yaml", "adapterId: a1\npluginParam:\n  interval: 5\n  hosts: [h1, h2]\n"},
+		{"plugin.json", `{"adapterId":"a1","pluginParam":{"interval":5,"hosts":["h1","h2"]}}`},
+		{"plugin.toml", "adapterId = \"a1\"\n[pluginParam]\ninterval = 5\nhosts = [\"h1\", \"h2\"]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg AdapterCfg
+			if err := LoadFile(writeFile(t, tt.name, tt.content), &cfg); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.AdapterId != "a1" {
+				t.Fatalf("AdapterId = %q, want a1", cfg.AdapterId)
+			}
+			if want := `{"hosts":["h1","h2"],"interval":5}`; cfg.PluginParam != want {
+				t.Fatalf("PluginParam = %s, want %s", cfg.PluginParam, want)
+			}
+		})
+	}
+
+	var cfg AdapterCfg
+	if err := LoadFile(writeFile(t, "plugin.ini", ""), &cfg); err == nil {
+		t.Fatal("LoadFile() accepted an unsupported format")
+	}
+}
+
+func TestConfigFlagOverridesFile(t *testing.T) {
+	fromSource := writeFile(t, "a.json", `{"adapterId":"source"}`)
+	fromFlag := writeFile(t, "b.json", `{"adapterId":"flag"}`)
+	src := Source{JSON: baseJSON, File: fromSource, Args: []string{"-config", fromFlag}}
+
+	if got := src.ConfigFile(); got != fromFlag {
+		t.Fatalf("ConfigFile() = %s, want %s", got, fromFlag)
+	}
+	cfg, err := Load(src)
+	if err != nil || cfg.AdapterId != "flag" {
+		t.Fatalf("Load() = %s, %v, want config from -config", cfg.AdapterId, err)
+	}
+}
+
+func TestLoadInvalidValues(t *testing.T) {
+	if _, err := Load(Source{JSON: baseJSON, Args: []string{"-plugin-rpc-use-tls=maybe"}}); err == nil {
+		t.Fatal("Load() accepted an invalid boolean flag")
+	}
+	t.Setenv("ELINK_ADAPTER_RPC_USE_TLS", "maybe")
+	if _, err := Load(Source{JSON: baseJSON}); err == nil || !strings.Contains(err.Error(), "ELINK_ADAPTER_RPC_USE_TLS") {
+		t.Fatalf("Load() error = %v, want invalid env reported", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "missing", "plugin.log")
+	cfg := AdapterCfg{
+		PluginRPC: PluginRPC{Address: "no-port", UseTLS: true, ClientAuth: true},
+		AdapterRPC: AdapterRPC{
+			CertFile: filepath.Join(dir, "client.crt"),
+			CAFile:   filepath.Join(dir, "ca.crt"),
+		},
+		Logger: LogConfig{Path: logPath, Level: "verbose"},
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want errors")
+	}
+	for _, want := range []string{
+		"adapterId is required",
+		"adapterRPC.address is required",
+		"pluginRPC.address \"no-port\" is invalid",
+		"pluginRPC.certFile and pluginRPC.keyFile are required",
+		"pluginRPC.clientAuth requires useTLS and caFile",
+		"adapterRPC.certFile and adapterRPC.keyFile must be set together",
+		"adapterRPC.certFile:",
+		"adapterRPC.caFile:",
+		"logger.level \"verbose\" is invalid",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error missing %q:\n%v", want, err)
+		}
+	}
+	// 错误按固定顺序返回
+	msg := err.Error()
+	for i := 0; i < 10; i++ {
+		if again := cfg.Validate().Error(); again != msg {
+			t.Fatalf("Validate() errors changed order:\n%s\n---\n%s", msg, again)
+		}
+	}
+	if strings.Index(msg, "adapterRPC.certFile:") > strings.Index(msg, "adapterRPC.caFile:") {
+		t.Fatalf("Validate() errors out of order:\n%s", msg)
+	}
+	// 校验不创建日志目录
+	if _, err = os.Stat(filepath.Dir(logPath)); !os.IsNotExist(err) {
+		t.Fatal("Validate() created the log directory")
+	}
+
+	cfg = AdapterCfg{}
+	if err = cfg.Validate(); err == nil || !strings.Contains(err.Error(), "logger.path is required") {
+		t.Fatalf("Validate() error = %v, want empty log path rejected", err)
+	}
+
+	cfg = AdapterCfg{Logger: LogConfig{Path: dir}}
+	if err = cfg.Validate(); err == nil || !strings.Contains(err.Error(), "is a directory") {
+		t.Fatalf("Validate() error = %v, want log path directory rejected", err)
+	}
+}
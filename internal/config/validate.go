@@ -0,0 +1,88 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var logLevels = []string{"debug", "info", "warn", "error", "dpanic", "panic", "fatal"}
+
+// Validate 校验配置,返回全部错误
+func (d AdapterCfg) Validate() error {
+	var errs []error
+	if d.AdapterId == "" {
+		errs = append(errs, errors.New("adapterId is required"))
+	}
+	if d.AdapterRPC.Address == "" {
+		errs = append(errs, errors.New("adapterRPC.address is required"))
+	}
+	if d.PluginRPC.Address == "" {
+		errs = append(errs, errors.New("pluginRPC.address is required"))
+	} else if _, _, err := net.SplitHostPort(d.PluginRPC.Address); err != nil {
+		errs = append(errs, fmt.Errorf("pluginRPC.address %q is invalid: %w", d.PluginRPC.Address, err))
+	}
+	if d.PluginRPC.UseTLS && (d.PluginRPC.CertFile == "" || d.PluginRPC.KeyFile == "") {
+		errs = append(errs, errors.New("pluginRPC.certFile and pluginRPC.keyFile are required when useTLS is enabled"))
+	}
+	if d.PluginRPC.ClientAuth && (!d.PluginRPC.UseTLS || d.PluginRPC.CAFile == "") {
+		errs = append(errs, errors.New("pluginRPC.clientAuth requires useTLS and caFile"))
+	}
+	if (d.AdapterRPC.CertFile == "") != (d.AdapterRPC.KeyFile == "") {
+		errs = append(errs, errors.New("adapterRPC.certFile and adapterRPC.keyFile must be set together"))
+	}
+	// 按固定顺序检查,错误信息的顺序保持稳定
+	for _, f := range []struct{ name, file string }{
+		{"adapterRPC.certFile", d.AdapterRPC.CertFile},
+		{"adapterRPC.keyFile", d.AdapterRPC.KeyFile},
+		{"adapterRPC.caFile", d.AdapterRPC.CAFile},
+		{"pluginRPC.certFile", d.PluginRPC.CertFile},
+		{"pluginRPC.keyFile", d.PluginRPC.KeyFile},
+		{"pluginRPC.caFile", d.PluginRPC.CAFile},
+	} {
+		if f.file == "" {
+			continue
+		}
+		if _, err := os.Stat(f.file); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.name, err))
+		}
+	}
+	// 只检查路径,日志目录在创建日志时生成
+	if d.Logger.Path == "" {
+		errs = append(errs, errors.New("logger.path is required"))
+	} else if info, err := os.Stat(d.Logger.Path); err == nil && info.IsDir() {
+		errs = append(errs, fmt.Errorf("logger.path %q is a directory", d.Logger.Path))
+	} else if info, err := os.Stat(filepath.Dir(d.Logger.Path)); err == nil && !info.IsDir() {
+		errs = append(errs, fmt.Errorf("logger.path: %q is not a directory", filepath.Dir(d.Logger.Path)))
+	}
+	if d.Logger.Level != "" && !validLevel(d.Logger.Level) {
+		errs = append(errs, fmt.Errorf("logger.level %q is invalid, expected one of %s", d.Logger.Level, strings.Join(logLevels, ", ")))
+	}
+	return errors.Join(errs...)
+}
+
+func validLevel(level string) bool {
+	for _, l := range logLevels {
+		if strings.EqualFold(l, level) {
+			return true
+		}
+	}
+	return false
+}
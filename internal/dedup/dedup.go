@@ -0,0 +1,140 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package dedup
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/model"
+)
+
+const defaultMaxEntries = 10000
+
+type entry struct {
+	key      string
+	deviceId string
+	at       time.Time
+	resp     interface{}
+}
+
+// Cache 按设备与MsgId记录已处理的下行消息及其应答
+type Cache struct {
+	mu      sync.Mutex
+	policy  model.DedupPolicy
+	entries map[string]*list.Element
+	order   *list.List
+	now     func() time.Time
+}
+
+func NewCache() *Cache {
+	return &Cache{
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// SetClock 设置计算去重窗口使用的时钟
+func (c *Cache) SetClock(now func() time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+func (c *Cache) SetPolicy(policy model.DedupPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if policy.MaxEntries <= 0 {
+		policy.MaxEntries = defaultMaxEntries
+	}
+	c.policy = policy
+	c.evict(c.now())
+}
+
+// Begin 记录一条下行消息,重复时返回之前的应答(尚未应答时为nil)
+func (c *Cache) Begin(deviceId, msgId string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.policy.Window <= 0 || msgId == "" {
+		return nil, false
+	}
+	now := c.now()
+	c.evict(now)
+
+	k := key(deviceId, msgId)
+	if el, ok := c.entries[k]; ok {
+		return el.Value.(*entry).resp, true
+	}
+	c.entries[k] = c.order.PushBack(&entry{key: k, deviceId: deviceId, at: now})
+	for c.order.Len() > c.policy.MaxEntries {
+		c.remove(c.order.Front())
+	}
+	return nil, false
+}
+
+// Record 记录下行消息的应答,用于重复消息到达时重发
+func (c *Cache) Record(deviceId, msgId string, resp interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key(deviceId, msgId)]; ok {
+		el.Value.(*entry).resp = resp
+	}
+}
+
+// Forget 删除记录,插件处理失败时允许重试
+func (c *Cache) Forget(deviceId, msgId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key(deviceId, msgId)]; ok {
+		c.remove(el)
+	}
+}
+
+// RemoveById 删除设备的全部记录,设备删除后重新添加时不会误判重复
+func (c *Cache) RemoveById(deviceId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*entry).deviceId == deviceId {
+			c.remove(el)
+		}
+		el = next
+	}
+}
+
+func (c *Cache) evict(now time.Time) {
+	for el := c.order.Front(); el != nil; el = c.order.Front() {
+		if now.Sub(el.Value.(*entry).at) <= c.policy.Window {
+			return
+		}
+		c.remove(el)
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	delete(c.entries, el.Value.(*entry).key)
+	c.order.Remove(el)
+}
+
+func key(deviceId, msgId string) string {
+	return deviceId + "/" + msgId
+}
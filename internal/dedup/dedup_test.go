@@ -0,0 +1,112 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package dedup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/model"
+)
+
+func TestDisabledWithoutWindow(t *testing.T) {
+	c := NewCache()
+	c.Begin("d1", "m1")
+	if _, dup := c.Begin("d1", "m1"); dup {
+		t.Fatal("Begin() reported duplicate without a dedup window")
+	}
+}
+
+func TestDuplicateReplaysResponse(t *testing.T) {
+	c := NewCache()
+	c.SetPolicy(model.DedupPolicy{Window: time.Minute})
+
+	if _, dup := c.Begin("d1", "m1"); dup {
+		t.Fatal("first Begin() reported duplicate")
+	}
+	// 尚未应答时重复的消息返回nil
+	if resp, dup := c.Begin("d1", "m1"); !dup || resp != nil {
+		t.Fatalf("Begin() before Record = %v, %v, want duplicate without response", resp, dup)
+	}
+	c.Record("d1", "m1", "ok")
+	if resp, dup := c.Begin("d1", "m1"); !dup || resp != "ok" {
+		t.Fatalf("Begin() after Record = %v, %v, want replayed response", resp, dup)
+	}
+	if _, dup := c.Begin("d2", "m1"); dup {
+		t.Fatal("Begin() treated the same MsgId of another device as duplicate")
+	}
+	if _, dup := c.Begin("d1", ""); dup {
+		t.Fatal("Begin() deduplicated an empty MsgId")
+	}
+}
+
+func TestForgetAllowsRetry(t *testing.T) {
+	c := NewCache()
+	c.SetPolicy(model.DedupPolicy{Window: time.Minute})
+	c.Begin("d1", "m1")
+	c.Forget("d1", "m1")
+	if _, dup := c.Begin("d1", "m1"); dup {
+		t.Fatal("Begin() after Forget reported duplicate")
+	}
+}
+
+func TestWindowExpiry(t *testing.T) {
+	now := time.Now()
+	c := NewCache()
+	c.SetClock(func() time.Time { return now })
+	c.SetPolicy(model.DedupPolicy{Window: time.Minute})
+	c.Begin("d1", "m1")
+
+	now = now.Add(time.Minute)
+	if _, dup := c.Begin("d1", "m1"); !dup {
+		t.Fatal("Begin() did not report duplicate at the end of the window")
+	}
+	now = now.Add(time.Millisecond)
+	if _, dup := c.Begin("d1", "m1"); dup {
+		t.Fatal("Begin() reported duplicate after the window expired")
+	}
+}
+
+func TestMaxEntriesEvictsOldest(t *testing.T) {
+	c := NewCache()
+	c.SetPolicy(model.DedupPolicy{Window: time.Minute, MaxEntries: 2})
+	for _, id := range []string{"m1", "m2", "m3"} {
+		c.Begin("d1", id)
+	}
+	if _, dup := c.Begin("d1", "m3"); !dup {
+		t.Fatal("newest entry was evicted")
+	}
+	if _, dup := c.Begin("d1", "m1"); dup {
+		t.Fatal("oldest entry was not evicted")
+	}
+}
+
+func TestRemoveById(t *testing.T) {
+	c := NewCache()
+	c.SetPolicy(model.DedupPolicy{Window: time.Minute})
+	c.Begin("d1", "m1")
+	c.Begin("d1", "m2")
+	c.Begin("d2", "m1")
+
+	c.RemoveById("d1")
+	for _, msgId := range []string{"m1", "m2"} {
+		if _, dup := c.Begin("d1", msgId); dup {
+			t.Fatalf("Begin(d1, %s) after RemoveById reported duplicate", msgId)
+		}
+	}
+	if _, dup := c.Begin("d2", "m1"); !dup {
+		t.Fatal("RemoveById() removed messages of another device")
+	}
+}
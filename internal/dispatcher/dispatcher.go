@@ -0,0 +1,181 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ytuox/elink-sdk-go/model"
+)
+
+var (
+	ErrQueueFull = errors.New("command queue is full")
+	ErrTimeout   = errors.New("command timeout")
+	ErrCanceled  = errors.New("command canceled")
+)
+
+type HandleFunc func(ctx context.Context) error
+
+type task struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	deviceId string
+	fn       HandleFunc
+	done     chan error
+}
+
+type queue struct {
+	tasks []*task
+}
+
+// Dispatcher 按设备或自定义的键串行执行下行命令
+type Dispatcher struct {
+	mu      sync.Mutex
+	enabled bool
+	policy  model.DispatchPolicy
+	queues  map[string]*queue
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		queues: make(map[string]*queue),
+	}
+}
+
+func (d *Dispatcher) SetPolicy(policy model.DispatchPolicy) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.enabled = true
+	d.policy = policy
+}
+
+func (d *Dispatcher) Disable() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.enabled = false
+}
+
+// Do 将命令加入队列并等待执行完成,未启用时直接执行
+func (d *Dispatcher) Do(ctx context.Context, device model.Device, fn HandleFunc) error {
+	d.mu.Lock()
+	if !d.enabled {
+		d.mu.Unlock()
+		return fn(ctx)
+	}
+
+	key := device.Id
+	if d.policy.KeyFunc != nil {
+		if k := d.policy.KeyFunc(device); k != "" {
+			key = k
+		}
+	}
+	var (
+		tctx   context.Context
+		cancel context.CancelFunc
+	)
+	if d.policy.Timeout > 0 {
+		tctx, cancel = context.WithTimeout(ctx, d.policy.Timeout)
+	} else {
+		tctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	q, ok := d.queues[key]
+	if !ok {
+		q = &queue{}
+		d.queues[key] = q
+	}
+	if d.policy.QueueSize > 0 && len(q.tasks) > d.policy.QueueSize {
+		d.mu.Unlock()
+		return ErrQueueFull
+	}
+	t := &task{
+		ctx:      tctx,
+		cancel:   cancel,
+		deviceId: device.Id,
+		fn:       fn,
+		done:     make(chan error, 1),
+	}
+	q.tasks = append(q.tasks, t)
+	if len(q.tasks) == 1 {
+		go d.work(key, q)
+	}
+	d.mu.Unlock()
+
+	select {
+	case err := <-t.done:
+		return err
+	case <-tctx.Done():
+		return ctxError(tctx)
+	}
+}
+
+// RemoveById 取消设备所有排队中和执行中的命令
+func (d *Dispatcher) RemoveById(deviceId string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, q := range d.queues {
+		for _, t := range q.tasks {
+			if t.deviceId == deviceId {
+				t.cancel()
+			}
+		}
+	}
+}
+
+// work 依次执行队列中的命令,队首为正在执行的命令,队列为空时退出
+func (d *Dispatcher) work(key string, q *queue) {
+	for {
+		d.mu.Lock()
+		t := q.tasks[0]
+		d.mu.Unlock()
+
+		if t.ctx.Err() != nil {
+			t.done <- ctxError(t.ctx)
+		} else {
+			t.done <- run(t)
+		}
+
+		d.mu.Lock()
+		q.tasks = q.tasks[1:]
+		if len(q.tasks) == 0 {
+			delete(d.queues, key)
+			d.mu.Unlock()
+			return
+		}
+		d.mu.Unlock()
+	}
+}
+
+// run 执行命令,命令在队列协程中执行,panic 转换为错误避免进程退出
+func run(t *task) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("panic:%v", e)
+		}
+	}()
+	return t.fn(t.ctx)
+}
+
+func ctxError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return ErrCanceled
+}
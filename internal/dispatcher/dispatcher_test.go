@@ -0,0 +1,181 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/model"
+)
+
+var d1 = model.Device{Id: "d1"}
+
+func TestDisabledRunsDirectly(t *testing.T) {
+	d := NewDispatcher()
+	called := false
+	if err := d.Do(context.Background(), d1, func(context.Context) error {
+		called = true
+		return nil
+	}); err != nil || !called {
+		t.Fatalf("Do() = %v, called = %v", err, called)
+	}
+}
+
+func TestSameDeviceRunsSerially(t *testing.T) {
+	d := NewDispatcher()
+	d.SetPolicy(model.DispatchPolicy{})
+
+	var running, overlap int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = d.Do(context.Background(), d1, func(context.Context) error {
+				if atomic.AddInt32(&running, 1) > 1 {
+					atomic.StoreInt32(&overlap, 1)
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	if overlap != 0 {
+		t.Fatal("commands of the same device ran concurrently")
+	}
+}
+
+func TestDifferentKeysRunInParallel(t *testing.T) {
+	d := NewDispatcher()
+	d.SetPolicy(model.DispatchPolicy{Timeout: time.Second})
+
+	var started sync.WaitGroup
+	started.Add(2)
+	fn := func(ctx context.Context) error {
+		started.Done()
+		// 两条命令都开始执行后才返回,串行执行时会超时
+		started.Wait()
+		return nil
+	}
+	errs := make(chan error, 2)
+	for _, id := range []string{"d1", "d2"} {
+		go func(id string) {
+			errs <- d.Do(context.Background(), model.Device{Id: id}, fn)
+		}(id)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("Do() = %v", err)
+		}
+	}
+}
+
+func TestKeyFuncSharesQueue(t *testing.T) {
+	d := NewDispatcher()
+	d.SetPolicy(model.DispatchPolicy{
+		QueueSize: 1,
+		KeyFunc:   func(model.Device) string { return "COM1" },
+	})
+	release := make(chan struct{})
+	go d.Do(context.Background(), model.Device{Id: "d1"}, func(context.Context) error {
+		<-release
+		return nil
+	})
+	defer close(release)
+	waitQueued(t, d, "COM1", 1)
+	go d.Do(context.Background(), model.Device{Id: "d2"}, func(context.Context) error { return nil })
+	waitQueued(t, d, "COM1", 2)
+
+	err := d.Do(context.Background(), model.Device{Id: "d3"}, func(context.Context) error { return nil })
+	if !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Do() = %v, want ErrQueueFull", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	d := NewDispatcher()
+	d.SetPolicy(model.DispatchPolicy{Timeout: 20 * time.Millisecond})
+	err := d.Do(context.Background(), d1, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Do() = %v, want ErrTimeout", err)
+	}
+}
+
+func TestPanicBecomesError(t *testing.T) {
+	d := NewDispatcher()
+	d.SetPolicy(model.DispatchPolicy{})
+	err := d.Do(context.Background(), d1, func(context.Context) error {
+		panic("boom")
+	})
+	if err == nil || err.Error() != "panic:boom" {
+		t.Fatalf("Do() = %v, want panic error", err)
+	}
+	if err = d.Do(context.Background(), d1, func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("Do() after panic = %v", err)
+	}
+}
+
+func TestRemoveByIdCancels(t *testing.T) {
+	d := NewDispatcher()
+	d.SetPolicy(model.DispatchPolicy{})
+	errs := make(chan error, 1)
+	go func() {
+		errs <- d.Do(context.Background(), d1, func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		})
+	}()
+	waitQueued(t, d, "d1", 1)
+	d.RemoveById("d1")
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrCanceled) {
+			t.Fatalf("Do() = %v, want ErrCanceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Do() not canceled by RemoveById")
+	}
+}
+
+// waitQueued 等待队列中的命令数达到n
+func waitQueued(t *testing.T, d *Dispatcher, key string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		d.mu.Lock()
+		q, ok := d.queues[key]
+		got := 0
+		if ok {
+			got = len(q.tasks)
+		}
+		d.mu.Unlock()
+		if got >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("queue %s did not reach %d commands", key, n)
+}
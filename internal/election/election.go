@@ -0,0 +1,198 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package election
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/internal/logger"
+)
+
+const DefaultLeaseTime = 15 * time.Second
+
+var ErrStandby = errors.New("standby instance")
+
+// Lease 主实例租约,同一时刻最多只有一个持有者
+type Lease interface {
+	// Acquire 获取或续约租约,租约被其他实例持有且未过期时返回false
+	Acquire(holder string, ttl time.Duration) (bool, error)
+	// Release 释放自己持有的租约
+	Release(holder string) error
+}
+
+// Elector 通过定期续约租约选举主实例
+type Elector struct {
+	lease    Lease
+	holder   string
+	ttl      time.Duration
+	interval time.Duration
+	logger   logger.Logger
+	onChange func(leader bool)
+
+	leader  atomic.Bool
+	mu      sync.Mutex
+	stop    context.CancelFunc
+	closed  bool
+	stopped sync.WaitGroup
+
+	// 状态变化由单独的协程按顺序通知,回调阻塞时不影响续约
+	changeMu sync.Mutex
+	changes  []bool
+	wake     chan struct{}
+	done     chan struct{}
+}
+
+func NewElector(lease Lease, holder string, ttl, interval time.Duration, l logger.Logger, onChange func(leader bool)) *Elector {
+	if ttl <= 0 {
+		ttl = DefaultLeaseTime
+	}
+	if interval <= 0 || interval >= ttl {
+		interval = ttl / 3
+	}
+	return &Elector{
+		lease:    lease,
+		holder:   holder,
+		ttl:      ttl,
+		interval: interval,
+		logger:   l,
+		onChange: onChange,
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+}
+
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Start 开始选举,重复调用或 Stop 之后调用时不生效
+func (e *Elector) Start(ctx context.Context) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed || e.stop != nil {
+		return
+	}
+	ctx, e.stop = context.WithCancel(ctx)
+	e.stopped.Add(1)
+	go e.run(ctx)
+	if e.onChange != nil {
+		go e.notify()
+	}
+}
+
+// Stop 停止选举,主实例释放租约以便备实例立即接管
+func (e *Elector) Stop() {
+	e.mu.Lock()
+	e.closed = true
+	stop := e.stop
+	e.stop = nil
+	e.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	stop()
+	e.stopped.Wait()
+	if e.leader.Load() {
+		if err := e.lease.Release(e.holder); err != nil {
+			e.logger.Errorf("release leader lease error: %s", err)
+		}
+		e.setLeader(false)
+	}
+	// 通知协程发送完剩余的状态变化后退出,Stop不等待插件的回调返回
+	close(e.done)
+}
+
+func (e *Elector) run(ctx context.Context) {
+	defer e.stopped.Done()
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	var renewed time.Time
+	for {
+		ok, err := e.lease.Acquire(e.holder, e.ttl)
+		switch {
+		case err != nil:
+			e.logger.Errorf("acquire leader lease error: %s", err)
+			// 无法续约时在租约过期前退为备实例,避免出现两个主实例
+			if e.leader.Load() && time.Since(renewed) >= e.ttl-e.interval {
+				e.setLeader(false)
+			}
+		case ok:
+			renewed = time.Now()
+			e.setLeader(true)
+		default:
+			e.setLeader(false)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) setLeader(leader bool) {
+	if e.leader.Swap(leader) == leader {
+		return
+	}
+	if leader {
+		e.logger.Infof("instance %s became leader", e.holder)
+	} else {
+		e.logger.Warnf("instance %s became standby", e.holder)
+	}
+	if e.onChange == nil {
+		return
+	}
+	e.changeMu.Lock()
+	e.changes = append(e.changes, leader)
+	e.changeMu.Unlock()
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// notify 按发生顺序调用状态变化回调
+func (e *Elector) notify() {
+	for {
+		if e.deliver() {
+			continue
+		}
+		select {
+		case <-e.wake:
+		case <-e.done:
+			e.deliver()
+			return
+		}
+	}
+}
+
+// deliver 调用已记录的状态变化回调,没有待通知的变化时返回false
+func (e *Elector) deliver() bool {
+	e.changeMu.Lock()
+	changes := e.changes
+	e.changes = nil
+	e.changeMu.Unlock()
+	for _, leader := range changes {
+		e.onChange(leader)
+	}
+	return len(changes) > 0
+}
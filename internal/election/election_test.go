@@ -0,0 +1,216 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package election
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/internal/logger"
+	"github.com/ytuox/elink-sdk-go/pkg/storage"
+)
+
+func TestFileLeaseExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leader.lock")
+	a, err := NewFileLease(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewFileLease(path)
+
+	if ok, err := a.Acquire("a", 0); !ok || err != nil {
+		t.Fatalf("a.Acquire() = %v, %v, want lock", ok, err)
+	}
+	if ok, err := b.Acquire("b", 0); ok || err != nil {
+		t.Fatalf("b.Acquire() = %v, %v, want lock held by a", ok, err)
+	}
+	if ok, _ := a.Acquire("a", 0); !ok {
+		t.Fatal("a failed to renew its lock")
+	}
+
+	if err = a.Release("a"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := b.Acquire("b", 0); !ok {
+		t.Fatal("b.Acquire() after release failed")
+	}
+	if ok, _ := a.Acquire("a", 0); ok {
+		t.Fatal("a.Acquire() succeeded while b holds the lock")
+	}
+}
+
+func TestFileLeaseDetectsReplacedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leader.lock")
+	a, _ := NewFileLease(path)
+	b, _ := NewFileLease(path)
+	if ok, _ := a.Acquire("a", 0); !ok {
+		t.Fatal("a.Acquire() failed")
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := b.Acquire("b", 0); !ok {
+		t.Fatal("b.Acquire() on a new lock file failed")
+	}
+	if ok, _ := a.Acquire("a", 0); ok {
+		t.Fatal("a kept leadership after its lock file was replaced")
+	}
+}
+
+func TestStorageLease(t *testing.T) {
+	backend := storage.NewMemoryBackend()
+	a, b := NewStorageLease(backend), NewStorageLease(backend)
+
+	if ok, err := a.Acquire("a", time.Hour); !ok || err != nil {
+		t.Fatalf("a.Acquire() = %v, %v", ok, err)
+	}
+	if ok, _ := b.Acquire("b", time.Hour); ok {
+		t.Fatal("b.Acquire() succeeded while a holds the lease")
+	}
+	if err := b.Release("b"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := a.Acquire("a", time.Millisecond); !ok {
+		t.Fatal("a failed to renew its lease")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if ok, _ := b.Acquire("b", time.Hour); !ok {
+		t.Fatal("b.Acquire() after the lease expired failed")
+	}
+}
+
+type fakeLease struct {
+	mu       sync.Mutex
+	released bool
+	lost     bool
+	acquires int
+}
+
+func (l *fakeLease) Acquire(string, time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.acquires++
+	return !l.lost, nil
+}
+
+func (l *fakeLease) lose() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lost = true
+}
+
+func (l *fakeLease) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.acquires
+}
+
+func (l *fakeLease) Release(string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.released = true
+	return nil
+}
+
+func TestElectorStartStop(t *testing.T) {
+	lease := &fakeLease{}
+	changes := make(chan bool, 2)
+	e := NewElector(lease, "a", time.Second, 0, logger.NewLogger("", "error", "test"), func(leader bool) {
+		changes <- leader
+	})
+	e.Start(context.Background())
+	if leader := <-changes; !leader || !e.IsLeader() {
+		t.Fatal("elector did not become leader")
+	}
+
+	e.Stop()
+	if leader := <-changes; leader || e.IsLeader() {
+		t.Fatal("elector is still leader after Stop")
+	}
+	if !lease.released {
+		t.Fatal("Stop() did not release the lease")
+	}
+}
+
+func TestElectorStartAfterStop(t *testing.T) {
+	e := NewElector(&fakeLease{}, "a", time.Second, 0, logger.NewLogger("", "error", "test"), nil)
+	e.Stop()
+	e.Start(context.Background())
+	time.Sleep(10 * time.Millisecond)
+	if e.IsLeader() {
+		t.Fatal("Start() after Stop started the election")
+	}
+}
+
+func TestElectorBlockingCallback(t *testing.T) {
+	lease := &fakeLease{}
+	block := make(chan struct{})
+	var (
+		mu      sync.Mutex
+		changes []bool
+	)
+	e := NewElector(lease, "a", 30*time.Millisecond, 5*time.Millisecond, logger.NewLogger("", "error", "test"), func(leader bool) {
+		<-block
+		mu.Lock()
+		changes = append(changes, leader)
+		mu.Unlock()
+	})
+	e.Start(context.Background())
+	defer e.Stop()
+
+	// 回调阻塞时仍按时续约
+	deadline := time.Now().Add(5 * time.Second)
+	for lease.count() < 5 {
+		if time.Now().After(deadline) {
+			t.Fatal("lease was not renewed while the callback blocked")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	lease.lose()
+	for e.IsLeader() {
+		if time.Now().After(deadline) {
+			t.Fatal("elector did not step down while the callback blocked")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(block)
+	for {
+		mu.Lock()
+		got := append([]bool(nil), changes...)
+		mu.Unlock()
+		if len(got) == 2 {
+			if !got[0] || got[1] {
+				t.Fatalf("callbacks = %v, want [true false]", got)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("callbacks = %v, want [true false]", got)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestElectorStopTwice(t *testing.T) {
+	e := NewElector(&fakeLease{}, "a", time.Second, 0, logger.NewLogger("", "error", "test"), func(bool) {})
+	e.Start(context.Background())
+	e.Stop()
+	e.Stop()
+}
@@ -0,0 +1,100 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package election
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileLease 通过本地文件的排他锁(flock)选举主实例,用于同一主机上的多个实例。
+// 锁由操作系统维护,实例退出后自动释放,因此不使用租约时长
+type FileLease struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+func NewFileLease(path string) (*FileLease, error) {
+	if path == "" {
+		return nil, errors.New("required lock file")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return &FileLease{path: path}, nil
+}
+
+func (l *FileLease) Acquire(holder string, _ time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file != nil {
+		// 每次续约时确认锁文件没有被删除或替换,否则其他实例可能锁住了新文件
+		if l.held() {
+			return true, nil
+		}
+		l.close()
+	}
+
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return false, err
+	}
+	ok, err := tryLock(f)
+	if err != nil || !ok {
+		f.Close()
+		return false, err
+	}
+	// 记录持有者便于排查,锁本身不依赖文件内容
+	if err = f.Truncate(0); err == nil {
+		_, err = f.WriteAt([]byte(holder), 0)
+	}
+	if err != nil {
+		f.Close()
+		return false, err
+	}
+	l.file = f
+	return true, nil
+}
+
+func (l *FileLease) Release(string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// 不删除锁文件,删除后其他实例可能锁住不同的文件
+	return l.close()
+}
+
+func (l *FileLease) held() bool {
+	opened, err := l.file.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(l.path)
+	return err == nil && os.SameFile(opened, current)
+}
+
+// close 关闭文件,文件关闭后锁随之释放
+func (l *FileLease) close() error {
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,68 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package election
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/pkg/storage"
+)
+
+const leaseKey = "leader"
+
+type leaseRecord struct {
+	Holder string `json:"holder"`
+	Expire int64  `json:"expire"` // 租约过期时间戳(毫秒)
+}
+
+// StorageLease 租约记录保存在自定义存储中,依赖各实例的时钟基本同步。
+// 自定义存储只支持尽力而为的 CompareAndSet,极端情况下两个实例可能同时获得租约,需要严格互斥时使用文件锁
+type StorageLease struct {
+	store *storage.Store
+}
+
+func NewStorageLease(backend storage.Backend) *StorageLease {
+	return &StorageLease{
+		store: storage.New(backend).Namespace("ha"),
+	}
+}
+
+func (l *StorageLease) Acquire(holder string, ttl time.Duration) (bool, error) {
+	record, rev, ok, err := storage.GetRevision[leaseRecord](l.store, leaseKey)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	if ok && record.Holder != holder && record.Expire > now.UnixMilli() {
+		return false, nil
+	}
+	_, err = storage.CompareAndSet(l.store, leaseKey, rev, leaseRecord{
+		Holder: holder,
+		Expire: now.Add(ttl).UnixMilli(),
+	}, 0)
+	if errors.Is(err, storage.ErrConflict) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (l *StorageLease) Release(holder string) error {
+	record, ok, err := storage.Get[leaseRecord](l.store, leaseKey)
+	if err != nil || !ok || record.Holder != holder {
+		return err
+	}
+	return l.store.Delete(leaseKey)
+}
@@ -0,0 +1,173 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package filter
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/internal/cache"
+	"github.com/ytuox/elink-sdk-go/model"
+	"github.com/ytuox/elink-sdk-go/util"
+
+	"github.com/spf13/cast"
+)
+
+var errNotNumber = errors.New("not a number")
+
+type deviceState struct {
+	last     map[string]interface{}
+	lastFull time.Time
+}
+
+// ReportFilter 属性变化上报过滤器
+type ReportFilter struct {
+	mu              sync.Mutex
+	productProvider cache.ProductProvider
+	policies        map[string]model.ReportFilterPolicy
+	states          map[string]*deviceState
+	now             func() time.Time
+}
+
+func NewReportFilter(pc cache.ProductProvider) *ReportFilter {
+	return &ReportFilter{
+		productProvider: pc,
+		now:             time.Now,
+		policies:        make(map[string]model.ReportFilterPolicy),
+		states:          make(map[string]*deviceState),
+	}
+}
+
+// SetClock 设置计算全量上报间隔使用的时钟
+func (f *ReportFilter) SetClock(now func() time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+}
+
+func (f *ReportFilter) SetPolicy(productId string, policy model.ReportFilterPolicy) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.policies[productId] = policy
+}
+
+func (f *ReportFilter) RemovePolicy(productId string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.policies, productId)
+}
+
+func (f *ReportFilter) RemoveById(deviceId string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.states, deviceId)
+}
+
+// Filter 返回需要上报的属性,full 为 true 表示本次为全量上报
+// 产品未配置策略时原样返回
+func (f *ReportFilter) Filter(productId, deviceId string, data map[string]interface{}) (map[string]interface{}, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	policy, ok := f.policies[productId]
+	if !ok {
+		return data, false
+	}
+	state, ok := f.states[deviceId]
+	if !ok {
+		return data, true
+	}
+
+	if policy.IntegrityInterval > 0 && f.now().Sub(state.lastFull) >= policy.IntegrityInterval {
+		full := make(map[string]interface{}, len(state.last)+len(data))
+		for k, v := range state.last {
+			full[k] = v
+		}
+		for k, v := range data {
+			full[k] = v
+		}
+		return full, true
+	}
+
+	send := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		last, ok := state.last[k]
+		if !ok || f.changed(productId, k, policy.GetDeadband(k), last, v) {
+			send[k] = v
+		}
+	}
+	return send, false
+}
+
+// Commit 上报成功后记录已上报的值
+func (f *ReportFilter) Commit(productId, deviceId string, sent map[string]interface{}, full bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.policies[productId]; !ok {
+		return
+	}
+	state, ok := f.states[deviceId]
+	if !ok {
+		state = &deviceState{last: make(map[string]interface{}, len(sent))}
+		f.states[deviceId] = state
+	}
+	for k, v := range sent {
+		state.last[k] = v
+	}
+	if full {
+		state.lastFull = f.now()
+	}
+}
+
+func (f *ReportFilter) changed(productId, identifier string, db model.Deadband, last, current interface{}) bool {
+	lf, err1 := toFloat(last)
+	cf, err2 := toFloat(current)
+	if err1 != nil || err2 != nil {
+		return util.StringEncoder(last) != util.StringEncoder(current)
+	}
+
+	delta := math.Abs(cf - lf)
+	if delta == 0 {
+		return false
+	}
+
+	var band float64
+	switch db.Type {
+	case model.DeadbandAbsolute:
+		band = db.Value
+	case model.DeadbandPercent:
+		band = math.Abs(lf) * db.Value / 100
+		if ps, ok := f.productProvider.GetPropertySpecByIdentifier(productId, identifier); ok {
+			if ns, err := ps.Define.NumberSpecs(); err == nil && ns.Range() > 0 {
+				band = ns.Range() * db.Value / 100
+			}
+		}
+	}
+	return delta > band
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case json.Number:
+		return n.Float64()
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return cast.ToFloat64E(n)
+	}
+	return 0, errNotNumber
+}
@@ -0,0 +1,145 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package filter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/internal/cache"
+	"github.com/ytuox/elink-sdk-go/model"
+)
+
+func newFilter() *ReportFilter {
+	return NewReportFilter(cache.NewProductCache([]model.Product{{
+		Id: "p1",
+		Properties: []model.Property{
+			{ProductId: "p1", Identifier: "temp", Define: model.Define{Type: "float", Specs: `{"min":0,"max":200}`}},
+			{ProductId: "p1", Identifier: "mode", Define: model.Define{Type: "text"}},
+		},
+	}}))
+}
+
+func TestFilterWithoutPolicy(t *testing.T) {
+	f := newFilter()
+	data := map[string]interface{}{"temp": 1.0}
+	got, full := f.Filter("p1", "d1", data)
+	if full || !reflect.DeepEqual(got, data) {
+		t.Fatalf("Filter() = %v, %v, want data unchanged", got, full)
+	}
+}
+
+func TestFilterDeadband(t *testing.T) {
+	tests := []struct {
+		name     string
+		deadband model.Deadband
+		next     map[string]interface{}
+		want     map[string]interface{}
+	}{
+		{
+			name: "unchanged",
+			next: map[string]interface{}{"temp": 20.0, "mode": "auto"},
+			want: map[string]interface{}{},
+		},
+		{
+			name: "text changed",
+			next: map[string]interface{}{"mode": "manual"},
+			want: map[string]interface{}{"mode": "manual"},
+		},
+		{
+			name:     "within absolute deadband",
+			deadband: model.Deadband{Type: model.DeadbandAbsolute, Value: 0.5},
+			next:     map[string]interface{}{"temp": 20.4},
+			want:     map[string]interface{}{},
+		},
+		{
+			name:     "beyond absolute deadband",
+			deadband: model.Deadband{Type: model.DeadbandAbsolute, Value: 0.5},
+			next:     map[string]interface{}{"temp": 20.6},
+			want:     map[string]interface{}{"temp": 20.6},
+		},
+		{
+			// 取值范围为0~200,5%的死区为10
+			name:     "within percent deadband of range",
+			deadband: model.Deadband{Type: model.DeadbandPercent, Value: 5},
+			next:     map[string]interface{}{"temp": 29},
+			want:     map[string]interface{}{},
+		},
+		{
+			name:     "beyond percent deadband of range",
+			deadband: model.Deadband{Type: model.DeadbandPercent, Value: 5},
+			next:     map[string]interface{}{"temp": 31},
+			want:     map[string]interface{}{"temp": 31},
+		},
+		{
+			name: "new property",
+			next: map[string]interface{}{"humidity": 50},
+			want: map[string]interface{}{"humidity": 50},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFilter()
+			policy := model.NewReportFilterPolicy(0)
+			policy.DefaultDeadband = tt.deadband
+			f.SetPolicy("p1", policy)
+
+			first := map[string]interface{}{"temp": 20.0, "mode": "auto"}
+			sent, full := f.Filter("p1", "d1", first)
+			if !full || !reflect.DeepEqual(sent, first) {
+				t.Fatalf("first Filter() = %v, %v, want full report", sent, full)
+			}
+			f.Commit("p1", "d1", sent, full)
+
+			got, full := f.Filter("p1", "d1", tt.next)
+			if full {
+				t.Fatal("Filter() reported full, want incremental")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Filter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterIntegrityReport(t *testing.T) {
+	now := time.Now()
+	f := newFilter()
+	f.SetClock(func() time.Time { return now })
+	f.SetPolicy("p1", model.NewReportFilterPolicy(time.Minute))
+	f.Commit("p1", "d1", map[string]interface{}{"temp": 20.0, "mode": "auto"}, true)
+
+	if got, full := f.Filter("p1", "d1", map[string]interface{}{"temp": 20.0}); full || len(got) != 0 {
+		t.Fatalf("Filter() before the integrity interval = %v, %v, want nothing", got, full)
+	}
+	now = now.Add(time.Minute)
+	got, full := f.Filter("p1", "d1", map[string]interface{}{"temp": 20.0})
+	want := map[string]interface{}{"temp": 20.0, "mode": "auto"}
+	if !full || !reflect.DeepEqual(got, want) {
+		t.Fatalf("Filter() = %v, %v, want full report %v", got, full, want)
+	}
+}
+
+func TestFilterRemoveById(t *testing.T) {
+	f := newFilter()
+	f.SetPolicy("p1", model.NewReportFilterPolicy(0))
+	f.Commit("p1", "d1", map[string]interface{}{"temp": 20.0}, true)
+	f.RemoveById("d1")
+
+	if _, full := f.Filter("p1", "d1", map[string]interface{}{"temp": 20.0}); !full {
+		t.Fatal("Filter() after RemoveById should start with a full report")
+	}
+}
@@ -16,6 +16,8 @@ package logger
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 
 	"github.com/natefinch/lumberjack"
 	"go.uber.org/zap"
@@ -91,6 +93,8 @@ func initLogger(lc *LoggerConfig) Logger {
 		}
 	}
 
+	// 目录创建失败时由写入日志时报告
+	_ = os.MkdirAll(filepath.Dir(lc.LogPath), 0o755)
 	writeSyncer := getLogWriter(lc)
 	encoder := getEncoder()
 	core := zapcore.NewCore(encoder, writeSyncer, level.Level())
@@ -0,0 +1,48 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package middleware
+
+import (
+	"sync"
+
+	"github.com/ytuox/elink-sdk-go/interfaces"
+)
+
+// Chain 下行消息中间件链,先注册的中间件在外层
+type Chain struct {
+	mu          sync.RWMutex
+	middlewares []interfaces.Middleware
+}
+
+func NewChain() *Chain {
+	return &Chain{}
+}
+
+func (c *Chain) Use(mw ...interfaces.Middleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.middlewares = append(c.middlewares, mw...)
+}
+
+func (c *Chain) Then(final interfaces.DownlinkHandler) interfaces.DownlinkHandler {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	h := final
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		h = c.middlewares[i](h)
+	}
+	return h
+}
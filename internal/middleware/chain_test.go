@@ -0,0 +1,114 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ytuox/elink-sdk-go/interfaces"
+	"github.com/ytuox/elink-sdk-go/model"
+)
+
+// record 记录中间件的执行顺序
+func record(name string, calls *[]string) interfaces.Middleware {
+	return func(next interfaces.DownlinkHandler) interfaces.DownlinkHandler {
+		return func(ctx context.Context, msg *model.DownlinkMessage) error {
+			*calls = append(*calls, name+" before")
+			err := next(ctx, msg)
+			*calls = append(*calls, name+" after")
+			return err
+		}
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	c := NewChain()
+	c.Use(record("a", &calls), record("b", &calls))
+	c.Use(record("c", &calls))
+
+	h := c.Then(func(context.Context, *model.DownlinkMessage) error {
+		calls = append(calls, "final")
+		return nil
+	})
+	if err := h(context.Background(), new(model.DownlinkMessage)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a before", "b before", "c before", "final", "c after", "b after", "a after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestChainShortCircuit(t *testing.T) {
+	var calls []string
+	denied := errors.New("denied")
+	deny := func(interfaces.DownlinkHandler) interfaces.DownlinkHandler {
+		return func(context.Context, *model.DownlinkMessage) error {
+			calls = append(calls, "deny")
+			return denied
+		}
+	}
+	c := NewChain()
+	c.Use(record("a", &calls), deny, record("b", &calls))
+
+	h := c.Then(func(context.Context, *model.DownlinkMessage) error {
+		calls = append(calls, "final")
+		return nil
+	})
+	if err := h(context.Background(), new(model.DownlinkMessage)); !errors.Is(err, denied) {
+		t.Fatalf("handler error = %v, want %v", err, denied)
+	}
+	want := []string{"a before", "deny", "a after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestChainPropagatesError(t *testing.T) {
+	failed := errors.New("device offline")
+	var seen []error
+	observe := func(next interfaces.DownlinkHandler) interfaces.DownlinkHandler {
+		return func(ctx context.Context, msg *model.DownlinkMessage) error {
+			err := next(ctx, msg)
+			seen = append(seen, err)
+			return err
+		}
+	}
+	c := NewChain()
+	c.Use(observe, observe)
+
+	h := c.Then(func(context.Context, *model.DownlinkMessage) error { return failed })
+	if err := h(context.Background(), new(model.DownlinkMessage)); !errors.Is(err, failed) {
+		t.Fatalf("handler error = %v, want %v", err, failed)
+	}
+	if len(seen) != 2 || seen[0] != failed || seen[1] != failed {
+		t.Fatalf("middlewares saw %v, want the final error twice", seen)
+	}
+}
+
+func TestEmptyChain(t *testing.T) {
+	called := false
+	h := NewChain().Then(func(context.Context, *model.DownlinkMessage) error {
+		called = true
+		return nil
+	})
+	if err := h(context.Background(), new(model.DownlinkMessage)); err != nil || !called {
+		t.Fatalf("handler error = %v, called = %v, want final handler called", err, called)
+	}
+}
@@ -0,0 +1,235 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+// Package params 解码插件参数,支持 default 标签设置默认值与 validate 标签校验取值
+//
+//	type Params struct {
+//		Interval time.Duration `json:"interval" default:"5s" validate:"min=1s"`
+//		Mode     string        `json:"mode" default:"poll" validate:"oneof=poll push"`
+//		Hosts    []string      `json:"hosts" validate:"required"`
+//	}
+//
+// default 标签中的 time.Duration 使用 time.ParseDuration 格式,JSON 中仍为纳秒数
+package params
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Decode 设置默认值后解码JSON格式的参数并校验,v必须为结构体指针
+func Decode(raw string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("params must be a non-nil pointer to struct")
+	}
+	if err := setDefaults(rv.Elem(), ""); err != nil {
+		return err
+	}
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), v); err != nil {
+			return fmt.Errorf("decode params: %w", err)
+		}
+	}
+	return Validate(v)
+}
+
+// Validate 按 validate 标签校验参数,返回全部错误
+func Validate(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return errors.New("params must be a struct")
+	}
+	return errors.Join(validate(rv, "")...)
+}
+
+func setDefaults(rv reflect.Value, path string) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fv := rv.Field(i)
+		name := fieldPath(path, sf)
+		if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+			if err := setDefaults(fv, name); err != nil {
+				return err
+			}
+			continue
+		}
+		def, ok := sf.Tag.Lookup("default")
+		if !ok || !fv.IsZero() {
+			continue
+		}
+		if err := setValue(fv, def); err != nil {
+			return fmt.Errorf("%s: invalid default %q: %w", name, def, err)
+		}
+	}
+	return nil
+}
+
+// setValue 将字符串转换为字段类型,切片使用逗号分隔
+func setValue(fv reflect.Value, s string) error {
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	case reflect.Slice:
+		parts := strings.Split(s, ",")
+		slice := reflect.MakeSlice(fv.Type(), len(parts), len(parts))
+		for i, p := range parts {
+			if err := setValue(slice.Index(i), strings.TrimSpace(p)); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
+
+func validate(rv reflect.Value, path string) []error {
+	var errs []error
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fv := rv.Field(i)
+		name := fieldPath(path, sf)
+		if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+			errs = append(errs, validate(fv, name)...)
+			continue
+		}
+		tag := sf.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		for _, rule := range strings.Split(tag, ",") {
+			if err := check(fv, rule); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	}
+	return errs
+}
+
+// check 校验单条规则: required、min=、max=、oneof=,字符串与切片的min/max比较长度
+func check(fv reflect.Value, rule string) error {
+	key, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+	switch key {
+	case "required":
+		if fv.IsZero() {
+			return errors.New("is required")
+		}
+	case "min", "max":
+		n, limit, err := measure(fv, arg)
+		if err != nil {
+			return fmt.Errorf("invalid rule %q: %w", rule, err)
+		}
+		if key == "min" && n < limit {
+			return fmt.Errorf("must be at least %s", arg)
+		}
+		if key == "max" && n > limit {
+			return fmt.Errorf("must be at most %s", arg)
+		}
+	case "oneof":
+		s := fmt.Sprint(fv.Interface())
+		for _, option := range strings.Fields(arg) {
+			if s == option {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of [%s]", arg)
+	default:
+		return fmt.Errorf("unknown rule %q", rule)
+	}
+	return nil
+}
+
+// measure 返回字段用于比较的数值及规则中的限值
+func measure(fv reflect.Value, arg string) (float64, float64, error) {
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(arg)
+		return float64(fv.Int()), float64(d), err
+	}
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	switch fv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return float64(fv.Len()), limit, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), limit, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), limit, nil
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), limit, nil
+	}
+	return 0, 0, fmt.Errorf("unsupported type %s", fv.Type())
+}
+
+// fieldPath 使用json标签中的名称拼接字段路径
+func fieldPath(path string, sf reflect.StructField) string {
+	name := sf.Name
+	if tag, _, _ := strings.Cut(sf.Tag.Get("json"), ","); tag != "" && tag != "-" {
+		name = tag
+	}
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,113 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package params
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testParams struct {
+	Interval time.Duration `json:"interval" default:"5s" validate:"min=1s"`
+	Mode     string        `json:"mode" default:"poll" validate:"oneof=poll push"`
+	Hosts    []string      `json:"hosts" default:"a, b" validate:"required,max=3"`
+	Retry    int           `json:"retry" default:"3" validate:"min=0,max=10"`
+	Enabled  bool          `json:"enabled" default:"true"`
+	Serial   serialParams  `json:"serial"`
+}
+
+type serialParams struct {
+	Port string  `json:"port" validate:"required"`
+	Baud uint    `json:"baud" default:"9600" validate:"oneof=9600 115200"`
+	Gain float64 `json:"gain" default:"1.5" validate:"max=2"`
+}
+
+func TestDecodeDefaults(t *testing.T) {
+	var p testParams
+	if err := Decode(`{"serial":{"port":"/dev/ttyS0"}}`, &p); err != nil {
+		t.Fatal(err)
+	}
+	want := testParams{
+		Interval: 5 * time.Second,
+		Mode:     "poll",
+		Hosts:    []string{"a", "b"},
+		Retry:    3,
+		Enabled:  true,
+		Serial:   serialParams{Port: "/dev/ttyS0", Baud: 9600, Gain: 1.5},
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Fatalf("Decode() = %+v, want %+v", p, want)
+	}
+}
+
+func TestDecodeOverridesDefaults(t *testing.T) {
+	var p testParams
+	raw := `{"interval":2000000000,"mode":"push","hosts":["h1"],"retry":0,"enabled":false,"serial":{"port":"COM1","baud":115200}}`
+	if err := Decode(raw, &p); err != nil {
+		t.Fatal(err)
+	}
+	// JSON中显式的零值覆盖默认值
+	if p.Interval != 2*time.Second || p.Mode != "push" || len(p.Hosts) != 1 || p.Retry != 0 || p.Enabled || p.Serial.Baud != 115200 {
+		t.Fatalf("Decode() = %+v, want JSON values over defaults", p)
+	}
+}
+
+func TestDecodeValidation(t *testing.T) {
+	var p testParams
+	raw := `{"interval":1000,"mode":"stream","hosts":["1","2","3","4"],"retry":11,"serial":{"baud":4800,"gain":3}}`
+	err := Decode(raw, &p)
+	if err == nil {
+		t.Fatal("Decode() = nil, want validation errors")
+	}
+	for _, want := range []string{
+		"interval: must be at least 1s",
+		"mode: must be one of [poll push]",
+		"hosts: must be at most 3",
+		"retry: must be at most 10",
+		"serial.port: is required",
+		"serial.baud: must be one of [9600 115200]",
+		"serial.gain: must be at most 2",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Decode() error missing %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	var p testParams
+	if err := Decode(`{}`, p); err == nil {
+		t.Error("Decode() accepted a non-pointer")
+	}
+	if err := Decode(`{"mode":`, &p); err == nil || !strings.Contains(err.Error(), "decode params") {
+		t.Errorf("Decode() error = %v, want decode error", err)
+	}
+
+	var bad struct {
+		N int `default:"x"`
+	}
+	if err := Decode("", &bad); err == nil || !strings.Contains(err.Error(), `invalid default "x"`) {
+		t.Errorf("Decode() error = %v, want invalid default", err)
+	}
+
+	var unknown struct {
+		N int `validate:"even"`
+	}
+	if err := Validate(unknown); err == nil || !strings.Contains(err.Error(), `unknown rule "even"`) {
+		t.Errorf("Validate() error = %v, want unknown rule", err)
+	}
+}
@@ -0,0 +1,62 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package server
+
+import (
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/internal/cache"
+	"github.com/ytuox/elink-sdk-go/internal/dedup"
+	"github.com/ytuox/elink-sdk-go/internal/dispatcher"
+	"github.com/ytuox/elink-sdk-go/internal/middleware"
+	"github.com/ytuox/elink-sdk-go/internal/validator"
+	"github.com/ytuox/elink-sdk-go/model"
+)
+
+// Responder 由SDK直接应答下行消息时使用的上行通道
+type Responder interface {
+	PropertySetResponse(deviceId string, data model.PropertySetResponse) error
+	PropertyGetResponse(deviceId string, data model.PropertyGetResponse) error
+	ServiceExecuteResponse(deviceId string, data model.ServiceExecuteResponse) error
+}
+
+// Acker 接收核心服务对上行消息的确认
+type Acker interface {
+	Done(deviceId, msgId, data string) bool
+}
+
+// Leader 主备模式下判断当前实例是否为主实例,备实例不处理下行消息
+type Leader interface {
+	IsLeader() bool
+}
+
+// DeviceRemover 设备删除时需要清理状态的组件
+type DeviceRemover interface {
+	RemoveById(deviceId string)
+}
+
+// Extensions 下行消息处理的扩展组件,为nil的组件不启用,Clock为nil时使用系统时间
+type Extensions struct {
+	Responder  Responder
+	Twin       cache.TwinProvider
+	Validator  *validator.Validator
+	Dispatcher *dispatcher.Dispatcher
+	Dedup      *dedup.Cache
+	Chain      *middleware.Chain
+	Acker      Acker
+	Leader     Leader
+	Removers   []DeviceRemover
+	Clock      func() time.Time
+}
@@ -17,13 +17,17 @@ import (
 	"github.com/ytuox/elink-sdk-go/common"
 	"github.com/ytuox/elink-sdk-go/interfaces"
 	"github.com/ytuox/elink-sdk-go/internal/cache"
+	"github.com/ytuox/elink-sdk-go/internal/certs"
 	"github.com/ytuox/elink-sdk-go/internal/client"
 	"github.com/ytuox/elink-sdk-go/internal/config"
+	"github.com/ytuox/elink-sdk-go/internal/dispatcher"
 	"github.com/ytuox/elink-sdk-go/internal/logger"
+	"github.com/ytuox/elink-sdk-go/internal/validator"
 	"github.com/ytuox/elink-sdk-go/model"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
@@ -45,6 +49,7 @@ type RPCService struct {
 	pluginProvider  interfaces.Plugin
 	logger          logger.Logger
 	cli             *client.ResourceClient
+	ext             Extensions
 	isRunning       bool
 }
 
@@ -103,6 +108,9 @@ func (server *RPCService) DeleteDeviceCallback(ctx context.Context, request *pb_
 		return new(emptypb.Empty), status.Errorf(codes.NotFound, "failed to find device %s", id)
 	}
 	server.deviceProvider.RemoveById(id)
+	for _, r := range server.ext.Removers {
+		r.RemoveById(id)
+	}
 	if err := server.pluginProvider.DeviceNotify(ctx, common.DeviceDeleteNotify, dev.Id, model.Device{}); err != nil {
 		return new(emptypb.Empty), status.Errorf(codes.Internal, err.Error())
 	}
@@ -156,6 +164,9 @@ func (server *RPCService) DeleteProductCallback(ctx context.Context, request *pb
 }
 
 func (server *RPCService) ThingModelMsgDown(ctx context.Context, request *pb_thingmodel.ThingModelMsgDownRequest) (*emptypb.Empty, error) {
+	if server.ext.Leader != nil && !server.ext.Leader.IsLeader() {
+		return new(emptypb.Empty), status.Errorf(codes.Unavailable, "standby instance")
+	}
 	deviceId := request.GetDeviceId()
 	device, ok := server.deviceProvider.SearchById(deviceId)
 	if !ok {
@@ -169,6 +180,12 @@ func (server *RPCService) ThingModelMsgDown(ctx context.Context, request *pb_thi
 			server.logger.Errorf("decode data error: %s", err)
 			return new(emptypb.Empty), status.Errorf(codes.Internal, "decode data error: %s", err)
 		}
+		if server.replayDuplicate(device, req.MsgId) {
+			return new(emptypb.Empty), nil
+		}
+		if server.rejectPropertySet(device, req) {
+			return new(emptypb.Empty), nil
+		}
 		req.Spec = make(map[string]model.Property, len(req.Data))
 		for k := range req.Data {
 			if ps, ok := server.productProvider.GetPropertySpecByIdentifier(device.ProductId, k); !ok {
@@ -178,9 +195,19 @@ func (server *RPCService) ThingModelMsgDown(ctx context.Context, request *pb_thi
 				req.Spec[k] = ps
 			}
 		}
-		err := server.pluginProvider.HandlePropertySet(ctx, deviceId, req)
+		err := server.handleDownlink(ctx, &model.DownlinkMessage{
+			Type:        model.DownlinkPropertySet,
+			Device:      device,
+			PropertySet: &req,
+		})
+		if st := dispatchStatus(err); st != nil {
+			server.logger.Errorf("dispatchPropertySet error: %s", err)
+			server.forget(deviceId, req.MsgId)
+			return new(emptypb.Empty), st
+		}
 		if err != nil {
 			server.logger.Errorf("handlePropertySet error: %s", err)
+			server.forget(deviceId, req.MsgId)
 			return new(emptypb.Empty), status.Errorf(codes.Unknown, err.Error())
 		}
 	case pb_thingmodel.OperationType_PROPERTY_GET:
@@ -199,7 +226,18 @@ func (server *RPCService) ThingModelMsgDown(ctx context.Context, request *pb_thi
 				req.Spec[k] = ps
 			}
 		}
-		err := server.pluginProvider.HandlePropertyGet(ctx, deviceId, req)
+		if server.answerFromTwin(device, req) {
+			return new(emptypb.Empty), nil
+		}
+		err := server.handleDownlink(ctx, &model.DownlinkMessage{
+			Type:        model.DownlinkPropertyGet,
+			Device:      device,
+			PropertyGet: &req,
+		})
+		if st := dispatchStatus(err); st != nil {
+			server.logger.Errorf("dispatchPropertyGet error: %s", err)
+			return new(emptypb.Empty), st
+		}
 		if err != nil {
 			server.logger.Errorf("handlePropertyGet error: %s", err)
 			return new(emptypb.Empty), status.Errorf(codes.Unknown, err.Error())
@@ -210,16 +248,47 @@ func (server *RPCService) ThingModelMsgDown(ctx context.Context, request *pb_thi
 			server.logger.Errorf("decode data error: %s", err)
 			return new(emptypb.Empty), status.Errorf(codes.Internal, "decode data error: %s", err)
 		}
+		if server.replayDuplicate(device, req.MsgId) {
+			return new(emptypb.Empty), nil
+		}
 
-		if action, ok := server.productProvider.GetServiceSpecByIdentifier(device.ProductId, req.Data.ServiceId); !ok {
+		action, ok := server.productProvider.GetServiceSpecByIdentifier(device.ProductId, req.Data.ServiceId)
+		if !ok {
 			server.logger.Warnf("can't find action(%s) spec in product(%s)", req.Data.ServiceId, device.ProductId)
 		} else {
 			req.Spec = action
 		}
+		if err := server.checkServiceInput(device, req, ok); err != nil {
+			server.forget(deviceId, req.MsgId)
+			return new(emptypb.Empty), err
+		}
 
-		err := server.pluginProvider.HandleServiceExecute(ctx, deviceId, req)
+		err := server.handleDownlink(ctx, &model.DownlinkMessage{
+			Type:           model.DownlinkServiceExecute,
+			Device:         device,
+			ServiceExecute: &req,
+		})
+		if st := dispatchStatus(err); st != nil {
+			server.logger.Errorf("dispatchServiceExecute error: %s", err)
+			server.forget(deviceId, req.MsgId)
+			return new(emptypb.Empty), st
+		}
 		if err != nil {
 			server.logger.Errorf("handleActionExecute error: %s", err)
+			server.forget(deviceId, req.MsgId)
+		}
+	case pb_thingmodel.OperationType_PROPERTY_REPORT_RESPONSE,
+		pb_thingmodel.OperationType_EVENT_REPORT_RESPONSE,
+		pb_thingmodel.OperationType_DATA_BATCH_REPORT_RESPONSE,
+		pb_thingmodel.OperationType_PROPERTY_DESIRED_GET_RESPONSE,
+		pb_thingmodel.OperationType_PROPERTY_DESIRED_DELETE_RESPONSE:
+		var req model.CommonRequest
+		if err := decoder(request.GetData(), &req); err != nil {
+			server.logger.Errorf("decode data error: %s", err)
+			return new(emptypb.Empty), status.Errorf(codes.Internal, "decode data error: %s", err)
+		}
+		if server.ext.Acker == nil || !server.ext.Acker.Done(deviceId, req.MsgId, request.GetData()) {
+			server.logger.Debugf("no pending message(%s) of device(%s) for %s", req.MsgId, deviceId, request.GetOperationType())
 		}
 	case pb_thingmodel.OperationType_CUSTOM_MQTT_PUBLISH:
 		//server.customMqttMessage.CustomMqttMessage("", request.Data)
@@ -229,21 +298,195 @@ func (server *RPCService) ThingModelMsgDown(ctx context.Context, request *pb_thi
 	return new(emptypb.Empty), nil
 }
 
+// replayDuplicate 重复的下行消息不再调用插件,已应答时重发之前的应答
+func (server *RPCService) replayDuplicate(device model.Device, msgId string) bool {
+	if server.ext.Dedup == nil {
+		return false
+	}
+	resp, dup := server.ext.Dedup.Begin(device.Id, msgId)
+	if !dup {
+		return false
+	}
+	server.logger.Warnf("duplicate message(%s) of device(%s)", msgId, device.Id)
+	if resp == nil || server.ext.Responder == nil {
+		return true
+	}
+
+	var err error
+	switch r := resp.(type) {
+	case model.PropertySetResponse:
+		err = server.ext.Responder.PropertySetResponse(device.Id, r)
+	case model.ServiceExecuteResponse:
+		err = server.ext.Responder.ServiceExecuteResponse(device.Id, r)
+	}
+	if err != nil {
+		server.logger.Errorf("replay response of message(%s) error: %s", msgId, err)
+	}
+	return true
+}
+
+func (server *RPCService) forget(deviceId, msgId string) {
+	if server.ext.Dedup != nil {
+		server.ext.Dedup.Forget(deviceId, msgId)
+	}
+}
+
+// handleDownlink 经过中间件链后调用插件处理下行消息
+func (server *RPCService) handleDownlink(ctx context.Context, msg *model.DownlinkMessage) error {
+	msg.Product, _ = server.productProvider.SearchById(msg.Device.ProductId)
+	if server.ext.Chain == nil {
+		return server.invokePlugin(ctx, msg)
+	}
+	return server.ext.Chain.Then(server.invokePlugin)(ctx, msg)
+}
+
+// HandleDownlink 处理SDK内部产生的下行消息,与核心服务下发的消息经过相同的中间件链与命令队列
+func (server *RPCService) HandleDownlink(ctx context.Context, msg *model.DownlinkMessage) error {
+	return server.handleDownlink(ctx, msg)
+}
+
+// invokePlugin 通过命令队列串行执行同一设备的下行命令
+func (server *RPCService) invokePlugin(ctx context.Context, msg *model.DownlinkMessage) error {
+	fn := func(ctx context.Context) error {
+		switch msg.Type {
+		case model.DownlinkPropertySet:
+			return server.pluginProvider.HandlePropertySet(ctx, msg.Device.Id, *msg.PropertySet)
+		case model.DownlinkPropertyGet:
+			return server.pluginProvider.HandlePropertyGet(ctx, msg.Device.Id, *msg.PropertyGet)
+		case model.DownlinkServiceExecute:
+			return server.pluginProvider.HandleServiceExecute(ctx, msg.Device.Id, *msg.ServiceExecute)
+		}
+		return fmt.Errorf("unsupported downlink type %s", msg.Type)
+	}
+	if server.ext.Dispatcher == nil {
+		return fn(ctx)
+	}
+	return server.ext.Dispatcher.Do(ctx, msg.Device, fn)
+}
+
+// dispatchStatus 将命令队列的错误转换为rpc错误,非队列错误返回nil
+func dispatchStatus(err error) error {
+	switch {
+	case errors.Is(err, dispatcher.ErrQueueFull):
+		return status.Errorf(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, dispatcher.ErrTimeout):
+		return status.Errorf(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, dispatcher.ErrCanceled):
+		return status.Errorf(codes.Canceled, err.Error())
+	}
+	return nil
+}
+
+// answerFromTwin 属性值均未过期时直接使用设备孪生应答属性查询
+func (server *RPCService) answerFromTwin(device model.Device, req model.PropertyGet) bool {
+	if server.ext.Twin == nil || server.ext.Responder == nil {
+		return false
+	}
+	data, ok := server.ext.Twin.Fresh(device.ProductId, device.Id, req.Data)
+	if !ok {
+		return false
+	}
+	resp := model.NewPropertyGetResponse(req.MsgId, server.now().UnixMilli(), data)
+	if err := server.ext.Responder.PropertyGetResponse(device.Id, resp); err != nil {
+		server.logger.Warnf("answer property get from twin error: %s", err)
+		return false
+	}
+	return true
+}
+
+func (server *RPCService) now() time.Time {
+	if server.ext.Clock != nil {
+		return server.ext.Clock()
+	}
+	return time.Now()
+}
+
+// rejectPropertySet 严格校验模式下拒绝不合法的属性下发并直接应答失败
+func (server *RPCService) rejectPropertySet(device model.Device, req model.PropertySet) bool {
+	if server.ext.Validator == nil {
+		return false
+	}
+	mode := server.ext.Validator.PropertySetMode()
+	if mode == model.ValidateOff {
+		return false
+	}
+	reasons := server.ext.Validator.CheckPropertySet(device.ProductId, req.Data)
+	if len(reasons) == 0 {
+		return false
+	}
+	msg := validator.Message(reasons)
+	if mode == model.ValidateLenient || server.ext.Responder == nil {
+		server.logger.Warnf("invalid property set of device(%s): %s", device.Id, msg)
+		return false
+	}
+
+	server.logger.Warnf("reject property set of device(%s): %s", device.Id, msg)
+	resp := model.NewPropertySetResponse(req.MsgId, model.PropertySetResponseData{
+		ErrorMessage: msg,
+		Code:         model.CodeInvalidParam,
+		Success:      false,
+	})
+	if err := server.ext.Responder.PropertySetResponse(device.Id, resp); err != nil {
+		server.logger.Errorf("response property set error: %s", err)
+	}
+	return true
+}
+
+// checkServiceInput 校验服务调用的输入参数,严格校验模式下返回错误
+func (server *RPCService) checkServiceInput(device model.Device, req model.ServiceExecuteRequest, found bool) error {
+	if server.ext.Validator == nil {
+		return nil
+	}
+	mode := server.ext.Validator.ServiceMode()
+	if mode == model.ValidateOff {
+		return nil
+	}
+
+	var msg string
+	if !found {
+		msg = "unknown service"
+	} else if reasons := validator.CheckParams(req.Spec.Input, req.Data.Input); len(reasons) > 0 {
+		msg = validator.Message(reasons)
+	} else {
+		return nil
+	}
+	if mode == model.ValidateLenient {
+		server.logger.Warnf("invalid service(%s) input of device(%s): %s", req.Data.ServiceId, device.Id, msg)
+		return nil
+	}
+	server.logger.Warnf("reject service(%s) of device(%s): %s", req.Data.ServiceId, device.Id, msg)
+	return status.Errorf(codes.InvalidArgument, "invalid service(%s) input: %s", req.Data.ServiceId, msg)
+}
+
 func NewRPCService(ctx context.Context, cfg config.PluginRPC, dc cache.DeviceProvider, pc cache.ProductProvider,
-	pluginProvider interfaces.Plugin, cli *client.ResourceClient, logger logger.Logger) (*RPCService, error) {
+	pluginProvider interfaces.Plugin, cli *client.ResourceClient, ext Extensions, logger logger.Logger, opts ...grpc.ServerOption) (*RPCService, error) {
 
 	if cfg.Address == "" {
 		logger.Error("required rpc address")
 		return nil, errors.New("required rpc address")
 	}
 
+	if cfg.UseTLS {
+		r, err := certs.NewReloader(certs.Files{CertFile: cfg.CertFile, KeyFile: cfg.KeyFile, CAFile: cfg.CAFile}, logger)
+		if err != nil {
+			logger.Errorf("failed to load certificates: %v", err)
+			return nil, err
+		}
+		tlsConfig, err := r.ServerConfig(cfg.ClientAuth)
+		if err != nil {
+			return nil, err
+		}
+		opts = append([]grpc.ServerOption{grpc.Creds(credentials.NewTLS(tlsConfig))}, opts...)
+	}
+
 	lis, err := net.Listen("tcp", cfg.Address)
 	if err != nil {
 		logger.Errorf("failed to listen: %v", err)
 		return nil, err
 	}
 
-	rpcs := grpc.NewServer(grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+	// 默认参数在前,opts可以覆盖
+	opts = append([]grpc.ServerOption{grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
 		MinTime:             5 * time.Second,
 		PermitWithoutStream: true,
 	}), grpc.KeepaliveParams(keepalive.ServerParameters{
@@ -252,7 +495,7 @@ func NewRPCService(ctx context.Context, cfg config.PluginRPC, dc cache.DevicePro
 		MaxConnectionAgeGrace: 5 * time.Second,
 		Time:                  5 * time.Second,
 		Timeout:               3 * time.Second,
-	}), grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	}), grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
 		defer func() {
 			if e := recover(); e != nil {
 				logger.Errorf("%s", debug.Stack())
@@ -261,7 +504,8 @@ func NewRPCService(ctx context.Context, cfg config.PluginRPC, dc cache.DevicePro
 		}()
 		reply, err := handler(ctx, req)
 		return reply, err
-	}))
+	})}, opts...)
+	rpcs := grpc.NewServer(opts...)
 
 	reflection.Register(rpcs)
 
@@ -274,6 +518,7 @@ func NewRPCService(ctx context.Context, cfg config.PluginRPC, dc cache.DevicePro
 		productProvider: pc,
 		pluginProvider:  pluginProvider,
 		cli:             cli,
+		ext:             ext,
 		logger:          logger,
 	}, nil
 }
@@ -316,6 +561,8 @@ func (s *RPCService) Stop() error {
 	s.logger.Info("Server shutting down")
 	_ = s.cli.Conn.Close()
 	s.rpcs.Stop()
+	// 未调用 Start 时监听不会被 grpc.Server 关闭
+	_ = s.lis.Close()
 	s.logger.Info("Server shut down")
 	return nil
 }
@@ -31,6 +31,9 @@ const (
 	startTime   int64 = 1525705533000 // 如果在程序跑了一段时间修改了epoch这个值 可能会导致生成相同的ID
 )
 
+// MaxWorkerId 节点id的最大值
+const MaxWorkerId = workerMax
+
 type Worker struct {
 	mu        sync.Mutex
 	timestamp int64
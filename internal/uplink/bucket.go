@@ -0,0 +1,55 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package uplink
+
+import (
+	"math"
+	"time"
+)
+
+// bucket 令牌桶,调用方负责加锁
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newBucket 创建装满令牌的令牌桶,now需与之后 reserve 使用的时间一致
+func newBucket(rate float64, burst int, now time.Time) *bucket {
+	b := math.Max(float64(burst), 1)
+	return &bucket{
+		rate:   rate,
+		burst:  b,
+		tokens: b,
+		last:   now,
+	}
+}
+
+// reserve 预占一个令牌,返回需要等待的时间
+func (b *bucket) reserve(now time.Time) time.Duration {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel 归还预占的令牌
+func (b *bucket) cancel() {
+	b.tokens++
+}
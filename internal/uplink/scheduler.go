@@ -0,0 +1,231 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package uplink
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/model"
+
+	pb_common "github.com/ytuox/elink-plugin-proto/common"
+)
+
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityHigh
+)
+
+const (
+	defaultWorkers   = 4
+	defaultLaneSize  = 1024
+	defaultMaxDelay  = 5 * time.Second
+	idleBucketExpiry = 10 * time.Minute
+)
+
+var ErrRateLimited = errors.New("uplink rate limited")
+
+type SendFunc func() (*pb_common.CommonResponse, error)
+
+type result struct {
+	resp *pb_common.CommonResponse
+	err  error
+}
+
+type job struct {
+	priority Priority
+	send     SendFunc
+	done     chan result
+}
+
+// Scheduler 上行消息限流与优先级调度
+type Scheduler struct {
+	ctx     context.Context
+	mu      sync.Mutex
+	once    sync.Once
+	enabled bool
+	policy  model.RateLimitPolicy
+	global  *bucket
+	devices map[string]*bucket
+	evicted time.Time
+	high    chan *job
+	low     chan *job
+
+	sent    uint64
+	dropped uint64
+	delayed uint64
+}
+
+func NewScheduler(ctx context.Context) *Scheduler {
+	return &Scheduler{
+		ctx:     ctx,
+		devices: make(map[string]*bucket),
+		high:    make(chan *job, defaultLaneSize),
+		low:     make(chan *job, defaultLaneSize),
+	}
+}
+
+func (s *Scheduler) SetPolicy(policy model.RateLimitPolicy) {
+	if policy.Workers <= 0 {
+		policy.Workers = defaultWorkers
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = defaultMaxDelay
+	}
+
+	s.mu.Lock()
+	s.enabled = true
+	s.policy = policy
+	s.global = nil
+	if policy.GlobalRate > 0 {
+		s.global = newBucket(policy.GlobalRate, policy.GlobalBurst, time.Now())
+	}
+	s.devices = make(map[string]*bucket)
+	s.mu.Unlock()
+
+	s.once.Do(func() {
+		for i := 0; i < policy.Workers; i++ {
+			go s.work()
+		}
+	})
+}
+
+func (s *Scheduler) Disable() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enabled = false
+}
+
+func (s *Scheduler) Stats() model.UplinkStats {
+	return model.UplinkStats{
+		Sent:    atomic.LoadUint64(&s.sent),
+		Dropped: atomic.LoadUint64(&s.dropped),
+		Delayed: atomic.LoadUint64(&s.delayed),
+	}
+}
+
+// Submit 按设备限流后放入对应优先级的队列,等待发送结果
+// 设备限流只作用于低优先级消息,避免单个设备的属性上报挤占告警事件与命令应答
+func (s *Scheduler) Submit(deviceId string, priority Priority, send SendFunc) (*pb_common.CommonResponse, error) {
+	s.mu.Lock()
+	if !s.enabled {
+		s.mu.Unlock()
+		return send()
+	}
+	var wait time.Duration
+	if priority == PriorityLow && s.policy.DeviceRate > 0 {
+		now := time.Now()
+		b, ok := s.devices[deviceId]
+		if !ok {
+			b = newBucket(s.policy.DeviceRate, s.policy.DeviceBurst, now)
+			s.devices[deviceId] = b
+		}
+		if wait = b.reserve(now); wait > s.policy.MaxDelay {
+			b.cancel()
+			s.mu.Unlock()
+			atomic.AddUint64(&s.dropped, 1)
+			return nil, ErrRateLimited
+		}
+		s.evictIdle(now)
+	}
+	s.mu.Unlock()
+
+	if wait > 0 {
+		atomic.AddUint64(&s.delayed, 1)
+		time.Sleep(wait)
+	}
+
+	j := &job{priority: priority, send: send, done: make(chan result, 1)}
+	lane := s.low
+	if priority == PriorityHigh {
+		lane = s.high
+	}
+	select {
+	case lane <- j:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+		return nil, ErrRateLimited
+	}
+
+	select {
+	case r := <-j.done:
+		return r.resp, r.err
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	}
+}
+
+func (s *Scheduler) work() {
+	for {
+		select {
+		case j := <-s.high:
+			s.run(j)
+			continue
+		default:
+		}
+
+		select {
+		case j := <-s.high:
+			s.run(j)
+		case j := <-s.low:
+			s.run(j)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Scheduler) run(j *job) {
+	s.mu.Lock()
+	var wait time.Duration
+	if s.global != nil {
+		if wait = s.global.reserve(time.Now()); wait > s.policy.MaxDelay && j.priority == PriorityLow {
+			s.global.cancel()
+			s.mu.Unlock()
+			atomic.AddUint64(&s.dropped, 1)
+			j.done <- result{err: ErrRateLimited}
+			return
+		}
+	}
+	s.mu.Unlock()
+
+	if wait > 0 {
+		atomic.AddUint64(&s.delayed, 1)
+		time.Sleep(wait)
+	}
+	resp, err := j.send()
+	if err == nil {
+		atomic.AddUint64(&s.sent, 1)
+	}
+	j.done <- result{resp: resp, err: err}
+}
+
+// evictIdle 定期清理长时间没有上报的设备,调用方负责加锁
+func (s *Scheduler) evictIdle(now time.Time) {
+	if now.Sub(s.evicted) < idleBucketExpiry {
+		return
+	}
+	s.evicted = now
+	for id, b := range s.devices {
+		if now.Sub(b.last) > idleBucketExpiry {
+			delete(s.devices, id)
+		}
+	}
+}
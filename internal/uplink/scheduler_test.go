@@ -0,0 +1,125 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package uplink
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/model"
+
+	pb_common "github.com/ytuox/elink-plugin-proto/common"
+)
+
+func ok() (*pb_common.CommonResponse, error) {
+	return &pb_common.CommonResponse{Success: true}, nil
+}
+
+func TestBucketReserve(t *testing.T) {
+	now := time.Now()
+	b := newBucket(10, 2, now)
+	for i := 0; i < 2; i++ {
+		if wait := b.reserve(now); wait != 0 {
+			t.Fatalf("reserve() within burst = %v, want 0", wait)
+		}
+	}
+	if wait := b.reserve(now); wait != 100*time.Millisecond {
+		t.Fatalf("reserve() beyond burst = %v, want 100ms", wait)
+	}
+	b.cancel()
+	if wait := b.reserve(now.Add(100 * time.Millisecond)); wait != 0 {
+		t.Fatalf("reserve() after refill = %v, want 0", wait)
+	}
+}
+
+func TestDisabledSendsDirectly(t *testing.T) {
+	s := NewScheduler(context.Background())
+	if resp, err := s.Submit("d1", PriorityLow, ok); err != nil || !resp.GetSuccess() {
+		t.Fatalf("Submit() = %v, %v", resp, err)
+	}
+}
+
+func TestDeviceRateLimitsLowPriorityOnly(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewScheduler(ctx)
+	s.SetPolicy(model.RateLimitPolicy{DeviceRate: 0.1, DeviceBurst: 1, MaxDelay: 10 * time.Millisecond})
+
+	if _, err := s.Submit("d1", PriorityLow, ok); err != nil {
+		t.Fatalf("first Submit() = %v", err)
+	}
+	if _, err := s.Submit("d1", PriorityLow, ok); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("second Submit() = %v, want ErrRateLimited", err)
+	}
+	if _, err := s.Submit("d1", PriorityHigh, ok); err != nil {
+		t.Fatalf("high priority Submit() = %v, want not limited", err)
+	}
+	if _, err := s.Submit("d2", PriorityLow, ok); err != nil {
+		t.Fatalf("other device Submit() = %v, want separate bucket", err)
+	}
+
+	want := model.UplinkStats{Sent: 3, Dropped: 1}
+	if got := s.Stats(); got != want {
+		t.Fatalf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestHighPriorityFirst(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewScheduler(ctx)
+	s.SetPolicy(model.RateLimitPolicy{Workers: 1})
+
+	// 阻塞唯一的发送协程,使后续消息在队列中排队
+	started, release := make(chan struct{}), make(chan struct{})
+	go s.Submit("d1", PriorityLow, func() (*pb_common.CommonResponse, error) {
+		close(started)
+		<-release
+		return ok()
+	})
+	<-started
+
+	var (
+		mu    sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+	submit := func(name string, p Priority, lane chan *job) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Submit("d1", p, func() (*pb_common.CommonResponse, error) {
+				mu.Lock()
+				order = append(order, name)
+				mu.Unlock()
+				return ok()
+			})
+		}()
+		for len(lane) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	submit("low", PriorityLow, s.low)
+	submit("high", PriorityHigh, s.high)
+	close(release)
+	wg.Wait()
+
+	if len(order) != 2 || order[0] != "high" {
+		t.Fatalf("send order = %v, want high before low", order)
+	}
+}
@@ -0,0 +1,126 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package validator
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ytuox/elink-sdk-go/internal/cache"
+	"github.com/ytuox/elink-sdk-go/model"
+)
+
+// Validator 按物模型定义校验下行参数
+type Validator struct {
+	mu              sync.RWMutex
+	productProvider cache.ProductProvider
+	propertySetMode model.ValidateMode
+	serviceMode     model.ValidateMode
+}
+
+func NewValidator(pc cache.ProductProvider) *Validator {
+	return &Validator{
+		productProvider: pc,
+	}
+}
+
+func (v *Validator) SetPropertySetMode(mode model.ValidateMode) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.propertySetMode = mode
+}
+
+func (v *Validator) PropertySetMode() model.ValidateMode {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.propertySetMode
+}
+
+func (v *Validator) SetServiceMode(mode model.ValidateMode) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.serviceMode = mode
+}
+
+func (v *Validator) ServiceMode() model.ValidateMode {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.serviceMode
+}
+
+// CheckParams 校验服务的输入或输出参数,返回缺失、多余或类型错误的参数
+func CheckParams(defines []model.InputOutput, params map[string]interface{}) map[string]string {
+	reasons := make(map[string]string)
+	defined := make(map[string]struct{}, len(defines))
+	for _, io := range defines {
+		defined[io.Identifier] = struct{}{}
+		value, ok := params[io.Identifier]
+		if !ok {
+			reasons[io.Identifier] = "missing parameter"
+			continue
+		}
+		if err := io.Define.Validate(value); err != nil {
+			reasons[io.Identifier] = err.Error()
+		}
+	}
+	for k := range params {
+		if _, ok := defined[k]; !ok {
+			reasons[k] = "unknown parameter"
+		}
+	}
+	return reasons
+}
+
+// CheckPropertySet 校验属性下发请求,返回各属性的失败原因
+func (v *Validator) CheckPropertySet(productId string, data map[string]interface{}) map[string]string {
+	reasons := make(map[string]string)
+	for k, value := range data {
+		ps, ok := v.productProvider.GetPropertySpecByIdentifier(productId, k)
+		if !ok {
+			reasons[k] = "unknown property"
+			continue
+		}
+		if ps.ReadOnly() {
+			reasons[k] = "property is read-only"
+			continue
+		}
+		if value == nil || value == "" {
+			if ps.Required {
+				reasons[k] = "required property can't be empty"
+			}
+			continue
+		}
+		if err := ps.Define.Validate(value); err != nil {
+			reasons[k] = err.Error()
+		}
+	}
+	return reasons
+}
+
+// Message 将失败原因按属性标识排序后拼接
+func Message(reasons map[string]string) string {
+	keys := make([]string, 0, len(reasons))
+	for k := range reasons {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	msgs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		msgs = append(msgs, k+": "+reasons[k])
+	}
+	return strings.Join(msgs, "; ")
+}
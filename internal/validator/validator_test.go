@@ -0,0 +1,136 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package validator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ytuox/elink-sdk-go/internal/cache"
+	"github.com/ytuox/elink-sdk-go/model"
+)
+
+func TestCheckPropertySet(t *testing.T) {
+	number := model.Define{Type: model.DataTypeInt, Specs: `{"min":0,"max":100}`}
+	v := NewValidator(cache.NewProductCache([]model.Product{{
+		Id: "p1",
+		Properties: []model.Property{
+			{ProductId: "p1", Identifier: "level", Mode: "rw", Define: number},
+			{ProductId: "p1", Identifier: "name", Mode: "rw", Required: true, Define: model.Define{Type: model.DataTypeText}},
+			{ProductId: "p1", Identifier: "note", Define: model.Define{Type: model.DataTypeText}},
+			{ProductId: "p1", Identifier: "serial", Mode: "r", Define: model.Define{Type: model.DataTypeText}},
+		},
+	}}))
+
+	tests := []struct {
+		name      string
+		productId string
+		data      map[string]interface{}
+		want      map[string]string
+	}{
+		{
+			name: "valid",
+			data: map[string]interface{}{"level": 50, "name": "a", "note": ""},
+			want: map[string]string{},
+		},
+		{
+			name: "unknown",
+			data: map[string]interface{}{"color": "red"},
+			want: map[string]string{"color": "unknown property"},
+		},
+		{
+			name: "read-only",
+			data: map[string]interface{}{"serial": "s1"},
+			want: map[string]string{"serial": "property is read-only"},
+		},
+		{
+			name: "required",
+			data: map[string]interface{}{"name": ""},
+			want: map[string]string{"name": "required property can't be empty"},
+		},
+		{
+			name: "out of range and wrong type",
+			data: map[string]interface{}{"level": 120, "note": 1},
+			want: map[string]string{"level": "120 is greater than max 100", "note": "1 is not a text"},
+		},
+		{
+			name:      "unknown product",
+			productId: "p2",
+			data:      map[string]interface{}{"level": 1},
+			want:      map[string]string{"level": "unknown property"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			productId := tt.productId
+			if productId == "" {
+				productId = "p1"
+			}
+			if got := v.CheckPropertySet(productId, tt.data); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("CheckPropertySet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageSortsReasons(t *testing.T) {
+	got := Message(map[string]string{"b": "unknown property", "a": "property is read-only"})
+	if want := "a: property is read-only; b: unknown property"; got != want {
+		t.Fatalf("Message() = %q, want %q", got, want)
+	}
+}
+
+func TestCheckParams(t *testing.T) {
+	defines := []model.InputOutput{
+		{Identifier: "delay", Define: model.Define{Type: model.DataTypeInt, Specs: `{"min":0,"max":60}`}},
+		{Identifier: "force", Define: model.Define{Type: model.DataTypeBool}},
+	}
+	tests := []struct {
+		name    string
+		defines []model.InputOutput
+		params  map[string]interface{}
+		want    map[string]string
+	}{
+		{
+			name:    "valid",
+			defines: defines,
+			params:  map[string]interface{}{"delay": 10, "force": true},
+			want:    map[string]string{},
+		},
+		{
+			name:    "missing and extra",
+			defines: defines,
+			params:  map[string]interface{}{"delay": 10, "mode": "fast"},
+			want:    map[string]string{"force": "missing parameter", "mode": "unknown parameter"},
+		},
+		{
+			name:    "wrong type",
+			defines: defines,
+			params:  map[string]interface{}{"delay": 1.5, "force": "yes"},
+			want:    map[string]string{"delay": "1.5 is not an integer", "force": "yes is not a bool"},
+		},
+		{
+			name: "no parameters defined",
+			want: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckParams(tt.defines, tt.params); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("CheckParams() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package model
+
+import "time"
+
+type AggregateFunc string
+
+const (
+	AggregateMin   AggregateFunc = "min"
+	AggregateMax   AggregateFunc = "max"
+	AggregateAvg   AggregateFunc = "avg"
+	AggregateSum   AggregateFunc = "sum"
+	AggregateCount AggregateFunc = "count"
+	AggregateLast  AggregateFunc = "last"
+)
+
+type WindowType string
+
+const (
+	WindowTumbling WindowType = "tumbling" // 滚动窗口,窗口之间不重叠
+	WindowSliding  WindowType = "sliding"  // 滑动窗口,每隔Step输出最近Window内的统计值
+)
+
+// AggregatePolicy 属性聚合策略
+type AggregatePolicy struct {
+	Window    time.Duration
+	Type      WindowType
+	Step      time.Duration            // 滑动窗口的输出间隔,为0时等于Window
+	Functions map[AggregateFunc]string // 聚合函数及输出的属性标识,输出标识为空时使用原属性标识,各函数的输出标识不能相同
+}
+
+func NewAggregatePolicy(window time.Duration, functions map[AggregateFunc]string) AggregatePolicy {
+	return AggregatePolicy{
+		Window:    window,
+		Type:      WindowTumbling,
+		Functions: functions,
+	}
+}
+
+func (p AggregatePolicy) GetStep() time.Duration {
+	if p.Type == WindowSliding && p.Step > 0 && p.Step < p.Window {
+		return p.Step
+	}
+	return p.Window
+}
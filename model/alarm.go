@@ -0,0 +1,54 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package model
+
+import "time"
+
+type AlarmOperator string
+
+const (
+	AlarmGreater      AlarmOperator = ">"
+	AlarmGreaterEqual AlarmOperator = ">="
+	AlarmLess         AlarmOperator = "<"
+	AlarmLessEqual    AlarmOperator = "<="
+	AlarmEqual        AlarmOperator = "=="
+	AlarmNotEqual     AlarmOperator = "!="
+)
+
+// AlarmRule 属性阈值告警规则
+type AlarmRule struct {
+	Id         string
+	ProductId  string
+	Identifier string // 属性标识
+	Operator   AlarmOperator
+	Threshold  float64
+	Hysteresis float64                // 解除告警的回差,如 > 80 回差 5 时低于 75 才解除
+	Duration   time.Duration          // 条件持续满足该时长后才触发或解除
+	RaiseEvent string                 // 触发时上报的事件标识
+	ClearEvent string                 // 解除时上报的事件标识,为空时不上报
+	Params     map[string]interface{} // 附加的事件参数,与ValueParam、ThresholdParam一起须覆盖事件定义的全部参数,各事件只携带自己定义的参数
+
+	ValueParam     string // 携带当前属性值的事件参数标识,为空时不携带
+	ThresholdParam string // 携带阈值的事件参数标识,为空时不携带
+}
+
+// AlarmState 设备当前的告警状态
+type AlarmState struct {
+	RuleId     string
+	DeviceId   string
+	Identifier string
+	Value      interface{}
+	Timestamp  int64 // 告警触发时间
+}
@@ -20,6 +20,7 @@ type (
 	BatchReport struct {
 		CommonRequest `json:",inline"`
 		Data          BatchData `json:"data"`
+		Sys           *ACK      `json:"sys,omitempty"`
 	}
 	BatchProperty struct {
 		Value interface{} `json:"value"`
@@ -34,6 +35,13 @@ type (
 	}
 )
 
+// BatchReportResult 批量上报结果,全部消息上报成功时Success为true
+type BatchReportResult struct {
+	CommonResponse
+	Messages int // 已发送的消息数
+	Failed   int // 被拒绝的消息数
+}
+
 func NewBatchReport(data BatchData) BatchReport {
 
 	return BatchReport{
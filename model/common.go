@@ -20,10 +20,20 @@ import (
 
 const Version = "1.0"
 
+// ACK 上行消息的确认选项,Ack为1时等待核心服务确认该消息
 type ACK struct {
 	Ack int8 `json:"ack"`
 }
 
+// AckRequired 需要核心服务确认的上行消息
+func AckRequired() *ACK {
+	return &ACK{Ack: 1}
+}
+
+func (a *ACK) Required() bool {
+	return a != nil && a.Ack == 1
+}
+
 type CommonResponse struct {
 	//RequestId    string
 	ErrorMessage string
@@ -0,0 +1,23 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package model
+
+import "time"
+
+// DedupPolicy 下行消息去重策略,时间窗口内重复的MsgId不再调用插件,直接重发已应答的结果
+type DedupPolicy struct {
+	Window     time.Duration // 去重时间窗口,为0时不去重
+	MaxEntries int           // 最多记录的消息数,超出时淘汰最早的记录
+}
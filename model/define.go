@@ -0,0 +1,110 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package model
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ytuox/elink-sdk-go/util"
+
+	"github.com/spf13/cast"
+)
+
+// 物模型数据类型
+const (
+	DataTypeInt    = "int"
+	DataTypeFloat  = "float"
+	DataTypeDouble = "double"
+	DataTypeText   = "text"
+	DataTypeDate   = "date"
+	DataTypeBool   = "bool"
+	DataTypeEnum   = "enum"
+	DataTypeStruct = "struct"
+	DataTypeArray  = "array"
+)
+
+// NumberSpecs 数值类型的取值范围定义
+type NumberSpecs struct {
+	Min    float64
+	Max    float64
+	Step   float64
+	Unit   string
+	HasMin bool
+	HasMax bool
+}
+
+// Range 取值范围跨度,未定义完整范围时返回0
+func (s NumberSpecs) Range() float64 {
+	if !s.HasMin || !s.HasMax || s.Max <= s.Min {
+		return 0
+	}
+	return s.Max - s.Min
+}
+
+func (d Define) DataType() string {
+	return strings.ToLower(strings.TrimSpace(d.Type))
+}
+
+func (d Define) IsNumber() bool {
+	switch d.DataType() {
+	case DataTypeInt, DataTypeFloat, DataTypeDouble:
+		return true
+	}
+	return false
+}
+
+// SpecsMap 将Specs解析为键值对
+func (d Define) SpecsMap() (map[string]interface{}, error) {
+	specs := make(map[string]interface{})
+	if strings.TrimSpace(d.Specs) == "" {
+		return specs, nil
+	}
+	if err := util.StringDecoder(d.Specs, &specs); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+// NumberSpecs 解析数值类型的取值范围,min/max/step 兼容字符串与数字
+func (d Define) NumberSpecs() (NumberSpecs, error) {
+	var ns NumberSpecs
+	if !d.IsNumber() {
+		return ns, errors.New("not a number define")
+	}
+	specs, err := d.SpecsMap()
+	if err != nil {
+		return ns, err
+	}
+	if v, ok := specs["min"]; ok && v != "" {
+		if ns.Min, err = cast.ToFloat64E(v); err != nil {
+			return ns, err
+		}
+		ns.HasMin = true
+	}
+	if v, ok := specs["max"]; ok && v != "" {
+		if ns.Max, err = cast.ToFloat64E(v); err != nil {
+			return ns, err
+		}
+		ns.HasMax = true
+	}
+	if v, ok := specs["step"]; ok && v != "" {
+		if ns.Step, err = cast.ToFloat64E(v); err != nil {
+			return ns, err
+		}
+	}
+	ns.Unit = cast.ToString(specs["unit"])
+	return ns, nil
+}
@@ -0,0 +1,24 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package model
+
+import "time"
+
+// DispatchPolicy 下行命令排队策略,同一队列的命令串行执行,不同队列并行执行
+type DispatchPolicy struct {
+	QueueSize int                        // 每个队列最多等待的命令数,为0时不限制
+	Timeout   time.Duration              // 单条命令从入队到执行完成的超时时间,为0时不限制
+	KeyFunc   func(device Device) string // 命令所属的队列,为空时按设备id排队,可按串口等共享资源排队
+}
@@ -0,0 +1,45 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package model
+
+type DownlinkType string
+
+const (
+	DownlinkPropertySet    DownlinkType = "propertySet"
+	DownlinkPropertyGet    DownlinkType = "propertyGet"
+	DownlinkServiceExecute DownlinkType = "serviceExecute"
+)
+
+// DownlinkMessage 统一的下行消息,按Type使用对应的请求字段
+type DownlinkMessage struct {
+	Type           DownlinkType
+	Device         Device
+	Product        Product
+	PropertySet    *PropertySet
+	PropertyGet    *PropertyGet
+	ServiceExecute *ServiceExecuteRequest
+}
+
+func (m *DownlinkMessage) MsgId() string {
+	switch m.Type {
+	case DownlinkPropertySet:
+		return m.PropertySet.MsgId
+	case DownlinkPropertyGet:
+		return m.PropertyGet.MsgId
+	case DownlinkServiceExecute:
+		return m.ServiceExecute.MsgId
+	}
+	return ""
+}
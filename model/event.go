@@ -21,6 +21,7 @@ type (
 	EventReport struct {
 		CommonRequest `json:",inline"`
 		Data          EventData `json:"data"`
+		Sys           *ACK      `json:"sys,omitempty"`
 	}
 	EventData struct {
 		Identifier string                 `json:"identifier"`
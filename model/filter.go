@@ -0,0 +1,51 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package model
+
+import "time"
+
+type DeadbandType string
+
+const (
+	DeadbandAbsolute DeadbandType = "absolute" // 绝对值死区
+	DeadbandPercent  DeadbandType = "percent"  // 按属性取值范围百分比计算的死区
+)
+
+// Deadband 数值变化未超过死区时不上报
+type Deadband struct {
+	Type  DeadbandType
+	Value float64
+}
+
+// ReportFilterPolicy 变化上报策略,值未变化或变化量在死区内的属性不上报
+type ReportFilterPolicy struct {
+	DefaultDeadband   Deadband            // 未单独配置的数值属性使用的死区
+	Deadband          map[string]Deadband // 按属性标识配置的死区
+	IntegrityInterval time.Duration       // 全量上报周期,为0时不强制全量上报
+}
+
+func NewReportFilterPolicy(integrityInterval time.Duration) ReportFilterPolicy {
+	return ReportFilterPolicy{
+		Deadband:          make(map[string]Deadband),
+		IntegrityInterval: integrityInterval,
+	}
+}
+
+func (p ReportFilterPolicy) GetDeadband(identifier string) Deadband {
+	if db, ok := p.Deadband[identifier]; ok {
+		return db
+	}
+	return p.DefaultDeadband
+}
@@ -0,0 +1,33 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package model
+
+import "time"
+
+type HABackend string
+
+const (
+	HABackendStorage HABackend = "storage" // 租约记录保存在自定义存储中,适用于多主机部署
+	HABackendFile    HABackend = "file"    // 通过本地文件锁选举,适用于同一主机部署
+)
+
+// HAPolicy 主备模式配置,只有主实例上报数据与处理下行消息
+type HAPolicy struct {
+	Backend       HABackend
+	LockFile      string        // Backend为file时的锁文件路径,持有文件排他锁的实例为主实例
+	LeaseTime     time.Duration // 租约时长,主实例失效后备实例最迟在该时间后接管
+	RenewInterval time.Duration // 续约间隔,为0时取租约时长的1/3
+	InstanceId    string        // 实例标识,为空时使用主机名与进程号
+}
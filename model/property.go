@@ -14,6 +14,8 @@
 
 package model
 
+import "time"
+
 type (
 	PropertyData struct {
 		Value     interface{} `json:"value"`     // 上报的属性值
@@ -32,9 +34,11 @@ type (
 
 	// PropertyReport 属性上报 属性查询响应
 	PropertyReport struct {
-		MsgId     string                 `json:"msgId"`
-		Timestamp int64                  `json:"timestamp"`
-		Data      map[string]interface{} `json:"data"`
+		MsgId      string                 `json:"msgId"`
+		Timestamp  int64                  `json:"timestamp"`
+		Data       map[string]interface{} `json:"data"`
+		Historical bool                   `json:"historical,omitempty"` // 历史补传数据,平台不作为当前状态
+		Sys        *ACK                   `json:"sys,omitempty"`
 	}
 
 	// PropertyGet 属性查询
@@ -129,3 +133,23 @@ func NewPropertySetResponse(msgId string, data PropertySetResponseData) Property
 		Data:  data,
 	}
 }
+
+func NewPropertyDesiredGet(identifiers []string) PropertyDesiredGet {
+	return PropertyDesiredGet{
+		CommonRequest: CommonRequest{
+			Version:   Version,
+			Timestamp: time.Now().UnixMilli(),
+		},
+		Data: identifiers,
+	}
+}
+
+func NewPropertyDesiredDelete(data map[string]PropertyDesiredDeleteValue) PropertyDesiredDelete {
+	return PropertyDesiredDelete{
+		CommonRequest: CommonRequest{
+			Version:   Version,
+			Timestamp: time.Now().UnixMilli(),
+		},
+		Data: data,
+	}
+}
@@ -0,0 +1,56 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package model
+
+import (
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// RetryPolicy 调用核心服务失败时的重试策略,重试间隔按指数退避并叠加随机抖动
+type RetryPolicy struct {
+	Codes       []codes.Code // 可重试的错误码,为空时重试 Unavailable
+	MaxAttempts int          // 最多调用次数,包含首次调用
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Multiplier  float64
+	Jitter      float64 // 随机抖动比例,取值 0~1
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Codes:       []codes.Code{codes.Unavailable},
+		MaxAttempts: 3,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    2 * time.Second,
+		Multiplier:  2,
+		Jitter:      0.2,
+	}
+}
+
+// CircuitBreakerPolicy 熔断策略,核心服务连续不可用时快速失败
+type CircuitBreakerPolicy struct {
+	FailureThreshold int           // 连续失败多少次后熔断
+	OpenTimeout      time.Duration // 熔断持续时间,到期后放行一次探测调用
+}
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+)
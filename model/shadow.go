@@ -0,0 +1,113 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+type (
+	// ShadowDiffItem 影子与本地状态不一致的属性,影子中不存在该属性时Shadow为nil
+	ShadowDiffItem struct {
+		Identifier string
+		Shadow     *PropertyData
+		Local      PropertyData
+	}
+
+	// ShadowDiff 属性影子与本地状态的差异
+	ShadowDiff struct {
+		Changed []ShadowDiffItem // 影子值与本地值不同
+		Missing []ShadowDiffItem // 影子中缺少本地已有的属性
+		Stale   []ShadowDiffItem // 值相同但影子的时间戳早于本地
+	}
+)
+
+func (d ShadowDiff) Empty() bool {
+	return len(d.Changed) == 0 && len(d.Missing) == 0 && len(d.Stale) == 0
+}
+
+// LocalValues 本地状态中与影子不一致的属性值,按本地采样时间分组,用于重新上报
+func (d ShadowDiff) LocalValues() map[int64]map[string]interface{} {
+	groups := make(map[int64]map[string]interface{})
+	for _, items := range [][]ShadowDiffItem{d.Changed, d.Missing, d.Stale} {
+		for _, item := range items {
+			g, ok := groups[item.Local.Timestamp]
+			if !ok {
+				g = make(map[string]interface{})
+				groups[item.Local.Timestamp] = g
+			}
+			g[item.Identifier] = item.Local.Value
+		}
+	}
+	return groups
+}
+
+// ShadowValues 影子中与本地值不同的属性值,用于重新下发给设备
+func (d ShadowDiff) ShadowValues() map[string]interface{} {
+	data := make(map[string]interface{}, len(d.Changed))
+	for _, item := range d.Changed {
+		data[item.Identifier] = item.Shadow.Value
+	}
+	return data
+}
+
+// DiffPropertyShadow 比较属性影子与本地状态,只比较本地存在的属性
+func DiffPropertyShadow(shadow []PropertyShadowData, local map[string]PropertyData) ShadowDiff {
+	shadows := make(map[string]PropertyData, len(shadow))
+	for _, s := range shadow {
+		shadows[s.Identifier] = s.PropertyData
+	}
+	identifiers := make([]string, 0, len(local))
+	for id := range local {
+		identifiers = append(identifiers, id)
+	}
+	sort.Strings(identifiers)
+
+	var diff ShadowDiff
+	for _, id := range identifiers {
+		item := ShadowDiffItem{Identifier: id, Local: local[id]}
+		s, ok := shadows[id]
+		if !ok || s.Value == nil {
+			diff.Missing = append(diff.Missing, item)
+			continue
+		}
+		item.Shadow = &s
+		if !sameValue(s.Value, item.Local.Value) {
+			diff.Changed = append(diff.Changed, item)
+		} else if s.Timestamp < item.Local.Timestamp {
+			diff.Stale = append(diff.Stale, item)
+		}
+	}
+	return diff
+}
+
+// sameValue 数值按大小比较,其余类型按序列化结果比较
+func sameValue(a, b interface{}) bool {
+	if x, err := toNumber(a); err == nil {
+		y, err := toNumber(b)
+		return err == nil && x == y
+	}
+	x, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	y, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(x, y)
+}
@@ -0,0 +1,93 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffPropertyShadow(t *testing.T) {
+	shadow := []PropertyShadowData{
+		{Identifier: "temp", PropertyData: PropertyData{Value: 20.0, Timestamp: 100}},
+		{Identifier: "humidity", PropertyData: PropertyData{Value: 50, Timestamp: 100}},
+		{Identifier: "mode", PropertyData: PropertyData{Value: "auto", Timestamp: 300}},
+		{Identifier: "level", PropertyData: PropertyData{Value: nil, Timestamp: 100}},
+		{Identifier: "removed", PropertyData: PropertyData{Value: 1, Timestamp: 100}},
+	}
+	local := map[string]PropertyData{
+		"temp":     {Value: 25, Timestamp: 200},
+		"humidity": {Value: 50.0, Timestamp: 200},
+		"mode":     {Value: "auto", Timestamp: 200},
+		"level":    {Value: 3, Timestamp: 200},
+		"added":    {Value: true, Timestamp: 200},
+	}
+	diff := DiffPropertyShadow(shadow, local)
+
+	// 影子中有而本地没有的属性不计入差异
+	want := ShadowDiff{
+		Changed: []ShadowDiffItem{
+			{Identifier: "temp", Shadow: &PropertyData{Value: 20.0, Timestamp: 100}, Local: local["temp"]},
+		},
+		Missing: []ShadowDiffItem{
+			{Identifier: "added", Local: local["added"]},
+			{Identifier: "level", Local: local["level"]},
+		},
+		Stale: []ShadowDiffItem{
+			{Identifier: "humidity", Shadow: &PropertyData{Value: 50, Timestamp: 100}, Local: local["humidity"]},
+		},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Fatalf("DiffPropertyShadow() = %+v, want %+v", diff, want)
+	}
+	if diff.Empty() {
+		t.Fatal("Empty() = true")
+	}
+
+	wantLocal := map[int64]map[string]interface{}{200: {"temp": 25, "added": true, "level": 3, "humidity": 50.0}}
+	if got := diff.LocalValues(); !reflect.DeepEqual(got, wantLocal) {
+		t.Fatalf("LocalValues() = %v, want %v", got, wantLocal)
+	}
+	if got := diff.ShadowValues(); !reflect.DeepEqual(got, map[string]interface{}{"temp": 20.0}) {
+		t.Fatalf("ShadowValues() = %v, want changed shadow values", got)
+	}
+}
+
+func TestSameValue(t *testing.T) {
+	tests := []struct {
+		a, b interface{}
+		want bool
+	}{
+		{1, 1.0, true},
+		{"1", 1, false},
+		{"a", "a", true},
+		{true, false, false},
+		{map[string]interface{}{"x": 1}, map[string]interface{}{"x": 1}, true},
+		{[]interface{}{1, 2}, []interface{}{2, 1}, false},
+	}
+	for _, tt := range tests {
+		if got := sameValue(tt.a, tt.b); got != tt.want {
+			t.Fatalf("sameValue(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestEmptyDiff(t *testing.T) {
+	local := map[string]PropertyData{"temp": {Value: 20, Timestamp: 100}}
+	shadow := []PropertyShadowData{{Identifier: "temp", PropertyData: PropertyData{Value: 20, Timestamp: 100}}}
+	if diff := DiffPropertyShadow(shadow, local); !diff.Empty() {
+		t.Fatalf("DiffPropertyShadow() = %+v, want empty", diff)
+	}
+}
@@ -0,0 +1,32 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package model
+
+import "time"
+
+// Timeouts 调用核心服务的超时时间
+type Timeouts struct {
+	Dial time.Duration // 连接核心服务
+	RPC  time.Duration // 单次调用
+	Sync time.Duration // 同步设备与产品列表
+}
+
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Dial: 3 * time.Second,
+		RPC:  10 * time.Second,
+		Sync: 30 * time.Second,
+	}
+}
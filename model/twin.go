@@ -0,0 +1,37 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package model
+
+import "time"
+
+// TwinPolicy 设备孪生应答策略,属性值在MaxAge时效内时由SDK直接应答属性查询
+type TwinPolicy struct {
+	DefaultMaxAge time.Duration            // 未单独配置的属性使用的时效,为0表示不应答
+	MaxAge        map[string]time.Duration // 按属性标识配置的时效
+}
+
+func NewTwinPolicy(defaultMaxAge time.Duration) TwinPolicy {
+	return TwinPolicy{
+		DefaultMaxAge: defaultMaxAge,
+		MaxAge:        make(map[string]time.Duration),
+	}
+}
+
+func (p TwinPolicy) GetMaxAge(identifier string) time.Duration {
+	if d, ok := p.MaxAge[identifier]; ok {
+		return d
+	}
+	return p.DefaultMaxAge
+}
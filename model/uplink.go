@@ -0,0 +1,58 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package model
+
+import "time"
+
+const (
+	DefaultMaxPayloadSize = 256 * 1024
+	DefaultMaxKeys        = 500
+)
+
+// UplinkLimit 单条上行消息的大小限制,超出时自动拆分为多条消息
+type UplinkLimit struct {
+	MaxPayloadSize int // data字段序列化后的最大字节数,为0时不限制
+	MaxKeys        int // 单条消息最多包含的属性或事件个数,为0时不限制
+}
+
+func DefaultUplinkLimit() UplinkLimit {
+	return UplinkLimit{
+		MaxPayloadSize: DefaultMaxPayloadSize,
+		MaxKeys:        DefaultMaxKeys,
+	}
+}
+
+// BackfillResult 历史数据补传结果
+type BackfillResult struct {
+	Messages int // 已发送的消息数
+	Samples  int // 已发送的采样点数
+}
+
+// RateLimitPolicy 上行消息限流策略,事件与下行命令的应答优先于属性上报发送
+type RateLimitPolicy struct {
+	DeviceRate  float64       // 每个设备每秒允许的属性上报数,为0时不限制
+	DeviceBurst int           // 每个设备允许的突发消息数
+	GlobalRate  float64       // 所有设备每秒允许的上行消息数,为0时不限制
+	GlobalBurst int           // 所有设备允许的突发消息数
+	MaxDelay    time.Duration // 等待令牌的最长时间,超出时丢弃属性上报
+	Workers     int           // 并发发送的协程数
+}
+
+// UplinkStats 上行消息计数
+type UplinkStats struct {
+	Sent    uint64 // 发送成功数
+	Dropped uint64 // 因限流丢弃数
+	Delayed uint64 // 因限流延迟发送数
+}
@@ -0,0 +1,164 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+type ValidateMode int
+
+const (
+	ValidateOff     ValidateMode = iota // 不校验
+	ValidateLenient                     // 校验失败仅记录日志
+	ValidateStrict                      // 校验失败拒绝请求
+)
+
+// CodeInvalidParam SDK校验下行参数失败时应答的错误码
+const CodeInvalidParam uint32 = 400
+
+// ReadOnly 属性是否只读,读写模式中不包含写权限时为只读
+func (p Property) ReadOnly() bool {
+	mode := strings.ToLower(strings.TrimSpace(p.Mode))
+	return mode != "" && !strings.Contains(mode, "w")
+}
+
+// Validate 按物模型数据类型定义校验值
+func (d Define) Validate(v interface{}) error {
+	if v == nil {
+		return errors.New("value is null")
+	}
+	switch d.DataType() {
+	case DataTypeInt, DataTypeFloat, DataTypeDouble:
+		return d.validateNumber(v)
+	case DataTypeBool:
+		switch b := v.(type) {
+		case bool:
+			return nil
+		case string:
+			if _, err := cast.ToBoolE(b); err != nil {
+				return fmt.Errorf("%v is not a bool", v)
+			}
+			return nil
+		}
+		if n, err := toNumber(v); err != nil || (n != 0 && n != 1) {
+			return fmt.Errorf("%v is not a bool", v)
+		}
+	case DataTypeText:
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%v is not a text", v)
+		}
+		specs, err := d.SpecsMap()
+		if err != nil {
+			return nil
+		}
+		if l, ok := specs["length"]; ok {
+			if max := cast.ToInt(l); max > 0 && len([]rune(s)) > max {
+				return fmt.Errorf("text length exceeds %d", max)
+			}
+		}
+	case DataTypeDate:
+		if _, ok := v.(string); ok {
+			return nil
+		}
+		if _, err := toNumber(v); err != nil {
+			return fmt.Errorf("%v is not a date", v)
+		}
+	case DataTypeEnum:
+		specs, err := d.SpecsMap()
+		if err != nil || len(specs) == 0 {
+			return nil
+		}
+		if _, ok := specs[cast.ToString(v)]; !ok {
+			return fmt.Errorf("%v is not a valid enum value", v)
+		}
+	case DataTypeStruct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%v is not a struct", v)
+		}
+		return d.validateStruct(m)
+	case DataTypeArray:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return fmt.Errorf("%v is not an array", v)
+		}
+		specs, err := d.SpecsMap()
+		if err != nil {
+			return nil
+		}
+		if size := cast.ToInt(specs["size"]); size > 0 && rv.Len() > size {
+			return fmt.Errorf("array size exceeds %d", size)
+		}
+	}
+	return nil
+}
+
+func (d Define) validateNumber(v interface{}) error {
+	n, err := toNumber(v)
+	if err != nil {
+		return fmt.Errorf("%v is not a number", v)
+	}
+	if d.DataType() == DataTypeInt && n != math.Trunc(n) {
+		return fmt.Errorf("%v is not an integer", v)
+	}
+	ns, err := d.NumberSpecs()
+	if err != nil {
+		return nil
+	}
+	if ns.HasMin && n < ns.Min {
+		return fmt.Errorf("%v is less than min %v", v, ns.Min)
+	}
+	if ns.HasMax && n > ns.Max {
+		return fmt.Errorf("%v is greater than max %v", v, ns.Max)
+	}
+	return nil
+}
+
+// validateStruct 结构体成员定义为 [{"identifier":"","define":{"type":"","specs":""}}]
+func (d Define) validateStruct(v map[string]interface{}) error {
+	var members []InputOutput
+	if err := json.Unmarshal([]byte(d.Specs), &members); err != nil {
+		return nil
+	}
+	for _, m := range members {
+		mv, ok := v[m.Identifier]
+		if !ok {
+			continue
+		}
+		if err := m.Define.Validate(mv); err != nil {
+			return fmt.Errorf("%s: %s", m.Identifier, err)
+		}
+	}
+	return nil
+}
+
+func toNumber(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case json.Number:
+		return n.Float64()
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return cast.ToFloat64E(n)
+	}
+	return 0, errors.New("not a number")
+}
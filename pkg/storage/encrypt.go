@@ -0,0 +1,252 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ytuox/elink-sdk-go/util"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// encryptedMarker 加密数据的前缀,格式为 $enc1$<密钥id>$<nonce+密文>,不带前缀的数据视为明文
+var encryptedMarker = []byte("$enc1$")
+
+const keyIdSize = 8
+
+// keySalt 派生密钥使用的固定盐值,secret可能是人工设置的口令,使用scrypt增加暴力破解的成本
+var keySalt = []byte("elink-sdk-go/storage/v1")
+
+// EncryptedBackend 加密存储,只处理以prefix开头的键,其他插件数据(如 Storage 与主备选举记录)不受影响。
+// 写入时使用当前密钥加密,读取到旧密钥加密的数据时使用当前密钥重新加密后写回
+type EncryptedBackend struct {
+	backend Backend
+	prefix  string
+	keyId   string
+	keys    map[string][]byte
+}
+
+// NewEncryptedBackend 使用secret派生的密钥加密以prefix开头的键,oldSecrets为轮换前使用过的密钥。
+// 派生密钥的计算开销较大,应复用创建的实例
+func NewEncryptedBackend(backend Backend, prefix, secret string, oldSecrets ...string) (*EncryptedBackend, error) {
+	if secret == "" {
+		return nil, errors.New("required storage secret")
+	}
+	e := &EncryptedBackend{
+		backend: backend,
+		prefix:  prefix,
+		keys:    make(map[string][]byte, len(oldSecrets)+1),
+	}
+	for _, s := range oldSecrets {
+		id, key, err := deriveKey(s)
+		if err != nil {
+			return nil, err
+		}
+		e.keys[id] = key
+	}
+	var (
+		key []byte
+		err error
+	)
+	if e.keyId, key, err = deriveKey(secret); err != nil {
+		return nil, err
+	}
+	e.keys[e.keyId] = key
+	return e, nil
+}
+
+func deriveKey(secret string) (string, []byte, error) {
+	key, err := scrypt.Key([]byte(secret), keySalt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return "", nil, err
+	}
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])[:keyIdSize], key, nil
+}
+
+// WithBackend 返回使用相同密钥访问backend的加密存储,用于 Store.WithDirect
+func (e *EncryptedBackend) WithBackend(backend Backend) *EncryptedBackend {
+	c := *e
+	c.backend = backend
+	return &c
+}
+
+// IsEncrypted 判断数据是否为加密数据
+func IsEncrypted(value []byte) bool {
+	return bytes.HasPrefix(value, encryptedMarker)
+}
+
+func (e *EncryptedBackend) GetCustomStorage(keys []string) (map[string][]byte, error) {
+	if err := e.checkKeys(keys); err != nil {
+		return nil, err
+	}
+	kvs, err := e.backend.GetCustomStorage(keys)
+	if err != nil {
+		return nil, err
+	}
+	return e.decryptAll(kvs)
+}
+
+func (e *EncryptedBackend) PutCustomStorage(kvs map[string][]byte) error {
+	encrypted := make(map[string][]byte, len(kvs))
+	for k, v := range kvs {
+		if !strings.HasPrefix(k, e.prefix) {
+			return e.outside(k)
+		}
+		value, err := e.encrypt(v)
+		if err != nil {
+			return fmt.Errorf("encrypt key(%s): %w", k, err)
+		}
+		encrypted[k] = value
+	}
+	return e.backend.PutCustomStorage(encrypted)
+}
+
+func (e *EncryptedBackend) DeleteCustomStorage(keys []string) error {
+	if err := e.checkKeys(keys); err != nil {
+		return err
+	}
+	return e.backend.DeleteCustomStorage(keys)
+}
+
+// GetAllCustomStorage 获取以prefix开头的全部数据,无法解密的数据(如未配置的旧密钥加密的数据)不返回,
+// 列出数据时不重新加密旧密钥加密的数据
+func (e *EncryptedBackend) GetAllCustomStorage() (map[string][]byte, error) {
+	kvs, err := e.scoped()
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range kvs {
+		plain, err := e.decrypt(v)
+		if err != nil {
+			delete(kvs, k)
+			continue
+		}
+		kvs[k] = plain
+	}
+	return kvs, nil
+}
+
+// Migrate 使用当前密钥加密以prefix开头的明文数据及旧密钥加密的数据,返回重新写入的条数
+func (e *EncryptedBackend) Migrate() (int, error) {
+	kvs, err := e.scoped()
+	if err != nil {
+		return 0, err
+	}
+	plain := make(map[string][]byte)
+	for k, v := range kvs {
+		if id, ok := e.keyIdOf(v); ok && id == e.keyId {
+			continue
+		}
+		if plain[k], err = e.decrypt(v); err != nil {
+			return 0, fmt.Errorf("decrypt key(%s): %w", k, err)
+		}
+	}
+	if len(plain) == 0 {
+		return 0, nil
+	}
+	return len(plain), e.PutCustomStorage(plain)
+}
+
+// scoped 读取底层存储中以prefix开头的数据
+func (e *EncryptedBackend) scoped() (map[string][]byte, error) {
+	all, err := e.backend.GetAllCustomStorage()
+	if err != nil {
+		return nil, err
+	}
+	kvs := make(map[string][]byte)
+	for k, v := range all {
+		if strings.HasPrefix(k, e.prefix) {
+			kvs[k] = v
+		}
+	}
+	return kvs, nil
+}
+
+func (e *EncryptedBackend) checkKeys(keys []string) error {
+	for _, k := range keys {
+		if !strings.HasPrefix(k, e.prefix) {
+			return e.outside(k)
+		}
+	}
+	return nil
+}
+
+func (e *EncryptedBackend) outside(key string) error {
+	return fmt.Errorf("key(%s) is outside encrypted prefix %q", key, e.prefix)
+}
+
+// decryptAll 解密数据,旧密钥加密的数据重新加密后写回
+func (e *EncryptedBackend) decryptAll(kvs map[string][]byte) (map[string][]byte, error) {
+	var rotate map[string][]byte
+	for k, v := range kvs {
+		id, encrypted := e.keyIdOf(v)
+		plain, err := e.decrypt(v)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt key(%s): %w", k, err)
+		}
+		kvs[k] = plain
+		if encrypted && id != e.keyId {
+			if rotate == nil {
+				rotate = make(map[string][]byte)
+			}
+			rotate[k] = plain
+		}
+	}
+	if len(rotate) > 0 {
+		if err := e.PutCustomStorage(rotate); err != nil {
+			return nil, fmt.Errorf("rotate storage key: %w", err)
+		}
+	}
+	return kvs, nil
+}
+
+func (e *EncryptedBackend) encrypt(plain []byte) ([]byte, error) {
+	ciphertext, err := util.EncryptAESGCM(e.keys[e.keyId], plain)
+	if err != nil {
+		return nil, err
+	}
+	value := make([]byte, 0, len(encryptedMarker)+keyIdSize+1+len(ciphertext))
+	value = append(value, encryptedMarker...)
+	value = append(value, e.keyId...)
+	value = append(value, '$')
+	return append(value, ciphertext...), nil
+}
+
+func (e *EncryptedBackend) decrypt(value []byte) ([]byte, error) {
+	id, ok := e.keyIdOf(value)
+	if !ok {
+		return value, nil
+	}
+	key, ok := e.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %s", id)
+	}
+	return util.DecryptAESGCM(key, value[len(encryptedMarker)+keyIdSize+1:])
+}
+
+// keyIdOf 返回加密数据使用的密钥id,明文数据返回false
+func (e *EncryptedBackend) keyIdOf(value []byte) (string, bool) {
+	if !IsEncrypted(value) || len(value) < len(encryptedMarker)+keyIdSize+1 || value[len(encryptedMarker)+keyIdSize] != '$' {
+		return "", false
+	}
+	return string(value[len(encryptedMarker) : len(encryptedMarker)+keyIdSize]), true
+}
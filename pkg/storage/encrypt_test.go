@@ -0,0 +1,135 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package storage
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	backend := NewMemoryBackend()
+	e, err := NewEncryptedBackend(backend, "secure/", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(e).Namespace("secure")
+	if err = Put(s, "token", "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := backend.GetCustomStorage([]string{"secure/token"})
+	if !IsEncrypted(raw["secure/token"]) || bytes.Contains(raw["secure/token"], []byte("abc")) {
+		t.Fatalf("stored value = %q, want ciphertext", raw["secure/token"])
+	}
+	if v, ok, err := Get[string](s, "token"); err != nil || !ok || v != "abc" {
+		t.Fatalf("Get() = %v, %v, %v, want abc", v, ok, err)
+	}
+}
+
+func TestEncryptedReadsPlaintext(t *testing.T) {
+	backend := NewMemoryBackend()
+	if err := Put(New(backend).Namespace("secure"), "token", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	e, _ := NewEncryptedBackend(backend, "secure/", "secret")
+	if v, ok, err := Get[string](New(e).Namespace("secure"), "token"); err != nil || !ok || v != "abc" {
+		t.Fatalf("Get() plaintext = %v, %v, %v, want abc", v, ok, err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	backend := NewMemoryBackend()
+	old, _ := NewEncryptedBackend(backend, "secure/", "old")
+	if err := Put(New(old).Namespace("secure"), "token", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := backend.GetCustomStorage([]string{"secure/token"})
+
+	rotated, err := NewEncryptedBackend(backend, "secure/", "new", "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _, err := Get[string](New(rotated).Namespace("secure"), "token"); err != nil || v != "abc" {
+		t.Fatalf("Get() with old key = %v, %v", v, err)
+	}
+	after, _ := backend.GetCustomStorage([]string{"secure/token"})
+	if bytes.Equal(before["secure/token"], after["secure/token"]) {
+		t.Fatal("Get() did not re-encrypt data of the old key")
+	}
+
+	// 轮换后不再需要旧密钥
+	current, _ := NewEncryptedBackend(backend, "secure/", "new")
+	if v, _, err := Get[string](New(current).Namespace("secure"), "token"); err != nil || v != "abc" {
+		t.Fatalf("Get() with new key only = %v, %v", v, err)
+	}
+}
+
+func TestEncryptedScopedToPrefix(t *testing.T) {
+	backend := NewMemoryBackend()
+	plain := New(backend)
+	if err := Put(plain.Namespace("ha"), "leader", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Put(plain.Namespace("secure"), "token", "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	e, _ := NewEncryptedBackend(backend, "secure/", "secret")
+	if _, err := e.GetCustomStorage([]string{"ha/leader"}); err == nil {
+		t.Fatal("GetCustomStorage() outside the prefix succeeded")
+	}
+	if err := e.PutCustomStorage(map[string][]byte{"ha/leader": []byte("x")}); err == nil {
+		t.Fatal("PutCustomStorage() outside the prefix succeeded")
+	}
+
+	n, err := e.Migrate()
+	if err != nil || n != 1 {
+		t.Fatalf("Migrate() = %d, %v, want 1 key migrated", n, err)
+	}
+	raw, _ := backend.GetAllCustomStorage()
+	if IsEncrypted(raw["ha/leader"]) || !IsEncrypted(raw["secure/token"]) {
+		t.Fatal("Migrate() touched keys outside the prefix")
+	}
+	if v, _, err := Get[string](plain.Namespace("ha"), "leader"); err != nil || v != "a" {
+		t.Fatalf("plain Get() after Migrate = %v, %v", v, err)
+	}
+}
+
+func TestListSkipsUnknownKeys(t *testing.T) {
+	backend := NewMemoryBackend()
+	other, _ := NewEncryptedBackend(backend, "secure/", "other")
+	if err := Put(New(other).Namespace("secure"), "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	e, _ := NewEncryptedBackend(backend, "secure/", "secret")
+	s := New(e).Namespace("secure")
+	if err := Put(s, "b", 2); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := backend.GetCustomStorage([]string{"secure/a"})
+
+	keys, err := s.List("")
+	if err != nil || len(keys) != 1 || keys[0] != "b" {
+		t.Fatalf("List() = %v, %v, want only the readable key", keys, err)
+	}
+	if _, _, err = Get[int](s, "a"); err == nil {
+		t.Fatal("Get() of an unknown key id succeeded")
+	}
+	after, _ := backend.GetCustomStorage([]string{"secure/a"})
+	if !bytes.Equal(before["secure/a"], after["secure/a"]) {
+		t.Fatal("reading changed data encrypted with an unknown key")
+	}
+}
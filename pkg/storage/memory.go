@@ -0,0 +1,77 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package storage
+
+import "sync"
+
+// MemoryBackend 基于内存的存储,用于单元测试或无需持久化的场景
+type MemoryBackend struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		data: make(map[string][]byte),
+	}
+}
+
+func (m *MemoryBackend) GetCustomStorage(keys []string) (map[string][]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	kvs := make(map[string][]byte, len(keys))
+	for _, k := range keys {
+		if v, ok := m.data[k]; ok {
+			kvs[k] = clone(v)
+		}
+	}
+	return kvs, nil
+}
+
+func (m *MemoryBackend) PutCustomStorage(kvs map[string][]byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for k, v := range kvs {
+		m.data[k] = clone(v)
+	}
+	return nil
+}
+
+func (m *MemoryBackend) DeleteCustomStorage(keys []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, k := range keys {
+		delete(m.data, k)
+	}
+	return nil
+}
+
+func (m *MemoryBackend) GetAllCustomStorage() (map[string][]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	kvs := make(map[string][]byte, len(m.data))
+	for k, v := range m.data {
+		kvs[k] = clone(v)
+	}
+	return kvs, nil
+}
+
+func clone(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
@@ -0,0 +1,101 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/util"
+)
+
+var ErrConflict = errors.New("revision conflict")
+
+// CompareAndSet 数据的修订号与rev一致时写入,rev为0表示数据不存在或尚无修订号,返回新的修订号。
+// 共享同一 New 创建的存储的调用串行执行。核心服务的自定义存储不支持原子的比较写入,
+// 多个插件副本之间只能尽力检测冲突:写入后重新读取,修订号被其他副本覆盖时返回 ErrConflict,
+// 但两个副本在对方写入前都完成了校验时仍可能都返回成功,需要严格互斥时应使用文件锁等其他机制
+func CompareAndSet[T any](s *Store, key string, rev uint64, v T, ttl time.Duration) (uint64, error) {
+	data, err := util.ByteEncoder(v)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	full := s.Key(key)
+	cur, ok, err := s.get(s.direct, full)
+	if err != nil {
+		return 0, err
+	}
+	if cur.rev != rev || (!ok && rev != 0) {
+		return 0, ErrConflict
+	}
+	next, err := s.putRaw(full, data, cur.rev, expireAt(ttl))
+	if err != nil {
+		return 0, err
+	}
+	after, ok, err := s.get(s.direct, full)
+	if err != nil {
+		return 0, err
+	}
+	if !ok || after.rev != next {
+		return 0, ErrConflict
+	}
+	return next, nil
+}
+
+// Sweep 删除命名空间内已过期的数据,返回删除的条数
+func (s *Store) Sweep() (int, error) {
+	all, err := s.backend.GetAllCustomStorage()
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UnixMilli()
+	var expired []string
+	for k, raw := range all {
+		if !strings.HasPrefix(k, s.prefix) {
+			continue
+		}
+		if env, ok := parseEnvelope(raw); ok && env.expired(now) {
+			expired = append(expired, k)
+		}
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+	return len(expired), s.backend.DeleteCustomStorage(expired)
+}
+
+// StartSweeper 按interval定期清理过期数据,ctx结束时停止,onError可以为nil
+func (s *Store) StartSweeper(ctx context.Context, interval time.Duration, onError func(error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.Sweep(); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+}
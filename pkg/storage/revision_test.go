@@ -0,0 +1,114 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompareAndSet(t *testing.T) {
+	s := New(NewMemoryBackend())
+
+	rev, err := CompareAndSet(s, "lock", 0, "a", 0)
+	if err != nil || rev == 0 {
+		t.Fatalf("CompareAndSet() new key = %d, %v", rev, err)
+	}
+	if _, err = CompareAndSet(s, "lock", 0, "b", 0); !errors.Is(err, ErrConflict) {
+		t.Fatalf("CompareAndSet() existing key with rev 0 = %v, want ErrConflict", err)
+	}
+	if _, err = CompareAndSet(s, "lock", rev-1, "b", 0); !errors.Is(err, ErrConflict) {
+		t.Fatalf("CompareAndSet() stale rev = %v, want ErrConflict", err)
+	}
+
+	next, err := CompareAndSet(s, "lock", rev, "b", 0)
+	if err != nil || next <= rev {
+		t.Fatalf("CompareAndSet() current rev = %d, %v, want a newer revision", next, err)
+	}
+	v, got, ok, err := GetRevision[string](s, "lock")
+	if err != nil || !ok || v != "b" || got != next {
+		t.Fatalf("GetRevision() = %v, %d, %v, %v, want b at %d", v, got, ok, err, next)
+	}
+}
+
+func TestRevisionReadsDirectBackend(t *testing.T) {
+	cached, direct := NewMemoryBackend(), NewMemoryBackend()
+	if err := Put(New(direct), "k", 1); err != nil {
+		t.Fatal(err)
+	}
+	s := New(cached).WithDirect(direct)
+	if _, ok, _ := Get[int](s, "k"); ok {
+		t.Fatal("Get() bypassed the cached backend")
+	}
+	if v, rev, ok, err := GetRevision[int](s, "k"); err != nil || !ok || v != 1 || rev == 0 {
+		t.Fatalf("GetRevision() = %v, %d, %v, %v, want value from the direct backend", v, rev, ok, err)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	backend := NewMemoryBackend()
+	s := New(backend)
+	if err := PutTTL(s, "short", 1, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := PutTTL(s, "long", 2, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if keys, _ := s.List(""); !reflect.DeepEqual(keys, []string{"long"}) {
+		t.Fatalf("List() = %v, want expired key hidden", keys)
+	}
+	if n, err := s.Sweep(); err != nil || n != 1 {
+		t.Fatalf("Sweep() = %d, %v, want 1", n, err)
+	}
+	if raw, _ := backend.GetCustomStorage([]string{"short"}); len(raw) != 0 {
+		t.Fatal("Sweep() did not delete the expired key")
+	}
+	if _, ok, _ := Get[int](s, "long"); !ok {
+		t.Fatal("Get() lost an unexpired key")
+	}
+}
+
+func TestListPage(t *testing.T) {
+	s := New(NewMemoryBackend())
+	for _, k := range []string{"c", "a", "b", "d", "x"} {
+		if err := Put(s, "item/"+k, k); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		pages  [][]string
+		cursor string
+	)
+	for {
+		keys, next, err := s.ListPage("item/", cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, keys)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	want := [][]string{{"item/a", "item/b"}, {"item/c", "item/d"}, {"item/x"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Fatalf("ListPage() pages = %v, want %v", pages, want)
+	}
+}
@@ -0,0 +1,294 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+// Package storage 在插件自定义存储之上提供带命名空间与版本管理的JSON存储
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/util"
+)
+
+// Backend 自定义存储的底层实现,PluginService 实现了该接口
+type Backend interface {
+	GetCustomStorage(keys []string) (map[string][]byte, error)
+	PutCustomStorage(kvs map[string][]byte) error
+	DeleteCustomStorage(keys []string) error
+	GetAllCustomStorage() (map[string][]byte, error)
+}
+
+// Migration 将数据从版本 n 升级到 n+1
+type Migration func(data json.RawMessage) (json.RawMessage, error)
+
+// Schema 数据的当前版本及各版本的迁移函数,不使用信封格式写入的旧数据视为版本0
+type Schema struct {
+	Version    int
+	Migrations map[int]Migration
+}
+
+// envelope 存储的数据格式,记录写入时的数据版本、修订号与过期时间
+type envelope struct {
+	Version *int            `json:"_v"`
+	Data    json.RawMessage `json:"_d"`
+	Rev     uint64          `json:"_r,omitempty"`
+	Expire  int64           `json:"_e,omitempty"` // 过期时间戳(毫秒),为0时永不过期
+}
+
+func (e envelope) expired(now int64) bool {
+	return e.Expire > 0 && e.Expire <= now
+}
+
+// Store 带命名空间的存储,子命名空间共享底层存储、数据版本与 CompareAndSet 的锁
+type Store struct {
+	backend Backend
+	direct  Backend // 读取修订号使用的存储,不经过本地缓存
+	prefix  string
+	schema  *Schema
+	mu      *sync.Mutex
+}
+
+func New(backend Backend) *Store {
+	return &Store{
+		backend: backend,
+		direct:  backend,
+		schema:  &Schema{},
+		mu:      &sync.Mutex{},
+	}
+}
+
+// WithDirect 返回读取修订号时使用direct的存储,backend带本地缓存时用于绕过缓存,
+// 避免 GetRevision 与 CompareAndSet 读到缓存中的旧修订号
+func (s *Store) WithDirect(direct Backend) *Store {
+	c := *s
+	c.direct = direct
+	return &c
+}
+
+// WithSchema 返回使用指定数据版本的存储
+func (s *Store) WithSchema(schema Schema) *Store {
+	c := *s
+	c.schema = &schema
+	return &c
+}
+
+// Namespace 返回子命名空间,键名以 name/ 为前缀
+func (s *Store) Namespace(name string) *Store {
+	c := *s
+	c.prefix = s.prefix + name + "/"
+	return &c
+}
+
+// Device 返回设备的命名空间
+func (s *Store) Device(deviceId string) *Store {
+	return s.Namespace("device").Namespace(deviceId)
+}
+
+// Product 返回产品的命名空间
+func (s *Store) Product(productId string) *Store {
+	return s.Namespace("product").Namespace(productId)
+}
+
+func (s *Store) Backend() Backend {
+	return s.backend
+}
+
+// Key 返回键在底层存储中的完整名称
+func (s *Store) Key(key string) string {
+	return s.prefix + key
+}
+
+// Get 读取并解码数据,旧版本的数据迁移到当前版本后写回,过期的数据视为不存在
+func Get[T any](s *Store, key string) (T, bool, error) {
+	v, _, ok, err := get[T](s, s.backend, key)
+	return v, ok, err
+}
+
+// GetRevision 直接从底层存储读取数据及其修订号,修订号用于 CompareAndSet
+func GetRevision[T any](s *Store, key string) (T, uint64, bool, error) {
+	return get[T](s, s.direct, key)
+}
+
+func get[T any](s *Store, from Backend, key string) (T, uint64, bool, error) {
+	var v T
+	full := s.Key(key)
+	e, ok, err := s.get(from, full)
+	if err != nil || !ok {
+		return v, 0, false, err
+	}
+	if err = util.ByteDecoder(e.data, &v); err != nil {
+		return v, 0, false, fmt.Errorf("key(%s): %w", full, err)
+	}
+	return v, e.rev, true, nil
+}
+
+// Put 编码并写入数据
+func Put[T any](s *Store, key string, v T) error {
+	return PutTTL(s, key, v, 0)
+}
+
+// PutTTL 编码并写入数据,数据在ttl后过期,ttl为0时永不过期
+func PutTTL[T any](s *Store, key string, v T, ttl time.Duration) error {
+	data, err := util.ByteEncoder(v)
+	if err != nil {
+		return err
+	}
+	_, err = s.putRaw(s.Key(key), data, 0, expireAt(ttl))
+	return err
+}
+
+func (s *Store) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	full := make([]string, 0, len(keys))
+	for _, k := range keys {
+		full = append(full, s.Key(k))
+	}
+	return s.backend.DeleteCustomStorage(full)
+}
+
+// List 列出命名空间内以 prefix 开头的键,返回的键不包含命名空间前缀
+func (s *Store) List(prefix string) ([]string, error) {
+	keys, _, err := s.ListPage(prefix, "", 0)
+	return keys, err
+}
+
+// ListPage 按键名顺序分页列出命名空间内以 prefix 开头且未过期的键,
+// cursor 为上一页返回的 next,limit 为0时不分页,没有更多数据时 next 为空
+func (s *Store) ListPage(prefix, cursor string, limit int) (keys []string, next string, err error) {
+	all, err := s.backend.GetAllCustomStorage()
+	if err != nil {
+		return nil, "", err
+	}
+	match := s.Key(prefix)
+	now := time.Now().UnixMilli()
+	keys = make([]string, 0)
+	for k, raw := range all {
+		if !strings.HasPrefix(k, match) {
+			continue
+		}
+		key := strings.TrimPrefix(k, s.prefix)
+		if cursor != "" && key <= cursor {
+			continue
+		}
+		if env, ok := parseEnvelope(raw); ok && env.expired(now) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+		next = keys[limit-1]
+	}
+	return keys, next, nil
+}
+
+type entry struct {
+	data json.RawMessage
+	rev  uint64
+}
+
+// get 从from读取数据,过期数据删除后视为不存在
+func (s *Store) get(from Backend, full string) (entry, bool, error) {
+	kvs, err := from.GetCustomStorage([]string{full})
+	if err != nil {
+		return entry{}, false, err
+	}
+	raw, ok := kvs[full]
+	if !ok {
+		return entry{}, false, nil
+	}
+	env, _ := parseEnvelope(raw)
+	if env.expired(time.Now().UnixMilli()) {
+		if err = s.backend.DeleteCustomStorage([]string{full}); err != nil {
+			return entry{}, false, err
+		}
+		return entry{}, false, nil
+	}
+	data, migrated, err := s.decode(raw)
+	if err != nil {
+		return entry{}, false, fmt.Errorf("key(%s): %w", full, err)
+	}
+	e := entry{data: data, rev: env.Rev}
+	if migrated {
+		if e.rev, err = s.putRaw(full, data, env.Rev, env.Expire); err != nil {
+			return e, true, err
+		}
+	}
+	return e, true, nil
+}
+
+// putRaw 写入数据并返回新的修订号,修订号为写入时的纳秒时间戳且大于之前的修订号
+func (s *Store) putRaw(key string, data json.RawMessage, rev uint64, expire int64) (uint64, error) {
+	version := s.schema.Version
+	next := uint64(time.Now().UnixNano())
+	if next <= rev {
+		next = rev + 1
+	}
+	raw, err := util.ByteEncoder(envelope{Version: &version, Data: data, Rev: next, Expire: expire})
+	if err != nil {
+		return 0, err
+	}
+	return next, s.backend.PutCustomStorage(map[string][]byte{key: raw})
+}
+
+// parseEnvelope 解析信封格式,不是信封格式的旧数据返回false
+func parseEnvelope(raw []byte) (envelope, bool) {
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil || env.Version == nil || env.Data == nil {
+		return envelope{}, false
+	}
+	return env, true
+}
+
+// decode 解析信封格式并按版本依次迁移,返回数据是否发生了迁移
+func (s *Store) decode(raw []byte) (json.RawMessage, bool, error) {
+	var (
+		version int
+		data    = json.RawMessage(raw)
+	)
+	if env, ok := parseEnvelope(raw); ok {
+		version, data = *env.Version, env.Data
+	}
+	if version > s.schema.Version {
+		return nil, false, fmt.Errorf("unsupported data version %d", version)
+	}
+
+	migrated := version < s.schema.Version
+	for ; version < s.schema.Version; version++ {
+		m, ok := s.schema.Migrations[version]
+		if !ok {
+			return nil, false, fmt.Errorf("missing migration from version %d", version)
+		}
+		var err error
+		if data, err = m(data); err != nil {
+			return nil, false, fmt.Errorf("migrate from version %d: %w", version, err)
+		}
+	}
+	return data, migrated, nil
+}
+
+func expireAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).UnixMilli()
+}
@@ -0,0 +1,127 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package storage
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type config struct {
+	Name    string `json:"name"`
+	Retries int    `json:"retries"`
+}
+
+func TestPutGet(t *testing.T) {
+	s := New(NewMemoryBackend())
+	want := config{Name: "a", Retries: 3}
+	if err := Put(s, "cfg", want); err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := Get[config](s, "cfg")
+	if err != nil || !ok || got != want {
+		t.Fatalf("Get() = %v, %v, %v, want %v", got, ok, err, want)
+	}
+	if _, ok, err = Get[config](s, "missing"); ok || err != nil {
+		t.Fatalf("Get() missing key = %v, %v, want not found", ok, err)
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	backend := NewMemoryBackend()
+	s := New(backend)
+	d1, d2 := s.Device("d1"), s.Device("d2")
+	if err := Put(d1, "state", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := Put(d2, "state", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := Put(s.Product("p1"), "state", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _, _ := Get[int](d1, "state"); v != 1 {
+		t.Fatalf("device d1 state = %d, want 1", v)
+	}
+	if got := d2.Key("state"); got != "device/d2/state" {
+		t.Fatalf("Key() = %s, want device/d2/state", got)
+	}
+	keys, err := s.Namespace("device").List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"d1/state", "d2/state"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("List() = %v, want %v", keys, want)
+	}
+
+	if err = d1.Delete("state"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := Get[int](d1, "state"); ok {
+		t.Fatal("Get() after Delete found the key")
+	}
+	if _, ok, _ := Get[int](d2, "state"); !ok {
+		t.Fatal("Delete() removed a key of another namespace")
+	}
+}
+
+func TestMigrateLegacyData(t *testing.T) {
+	backend := NewMemoryBackend()
+	// 不使用信封格式写入的旧数据视为版本0
+	backend.PutCustomStorage(map[string][]byte{"cfg": []byte(`{"name":"a"}`)})
+
+	s := New(backend).WithSchema(Schema{
+		Version: 1,
+		Migrations: map[int]Migration{
+			0: func(data json.RawMessage) (json.RawMessage, error) {
+				var c config
+				if err := json.Unmarshal(data, &c); err != nil {
+					return nil, err
+				}
+				c.Retries = 3
+				return json.Marshal(c)
+			},
+		},
+	})
+	got, ok, err := Get[config](s, "cfg")
+	if err != nil || !ok || got != (config{Name: "a", Retries: 3}) {
+		t.Fatalf("Get() = %v, %v, %v, want migrated value", got, ok, err)
+	}
+
+	raw, _ := backend.GetCustomStorage([]string{"cfg"})
+	env, ok := parseEnvelope(raw["cfg"])
+	if !ok || *env.Version != 1 {
+		t.Fatalf("stored data = %s, want migrated envelope of version 1", raw["cfg"])
+	}
+}
+
+func TestSchemaErrors(t *testing.T) {
+	backend := NewMemoryBackend()
+	if err := Put(New(backend).WithSchema(Schema{Version: 2, Migrations: map[int]Migration{}}), "v2", 1); err != nil {
+		t.Fatal(err)
+	}
+	backend.PutCustomStorage(map[string][]byte{"v0": []byte(`1`)})
+
+	s := New(backend).WithSchema(Schema{Version: 1})
+	if _, _, err := Get[int](s, "v2"); err == nil || !strings.Contains(err.Error(), "unsupported data version 2") {
+		t.Fatalf("Get() newer version error = %v", err)
+	}
+	if _, _, err := Get[int](s, "v0"); err == nil || !strings.Contains(err.Error(), "missing migration from version 0") {
+		t.Fatalf("Get() without migration error = %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ytuox/elink-sdk-go/model"
+)
+
+func (d *PluginService) setAggregatePolicy(productId, identifier string, policy model.AggregatePolicy) error {
+	if _, ok := d.productCache.GetPropertySpecByIdentifier(productId, identifier); !ok {
+		return errors.New("property not found")
+	}
+	for _, target := range policy.Functions {
+		if target == "" {
+			continue
+		}
+		if _, ok := d.productCache.GetPropertySpecByIdentifier(productId, target); !ok {
+			return fmt.Errorf("output property(%s) not found", target)
+		}
+	}
+	return d.aggregator.SetPolicy(productId, identifier, policy)
+}
+
+func (d *PluginService) propertyAggregate(deviceId string, data map[string]interface{}) error {
+	device, ok := d.deviceCache.SearchById(deviceId)
+	if !ok {
+		return errors.New("device not found")
+	}
+	rest := d.aggregator.Add(device.ProductId, deviceId, data)
+	if len(rest) == 0 {
+		return nil
+	}
+	resp, err := d.propertyReport(deviceId, model.NewPropertyReport("", 0, rest))
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return errors.New(resp.ErrorMessage)
+	}
+	return nil
+}
+
+func (d *PluginService) emitAggregate(deviceId string, ts int64, data map[string]interface{}) {
+	resp, err := d.propertyReport(deviceId, model.NewPropertyReport("", ts, data))
+	if err != nil {
+		d.logger.Errorf("report aggregate of device(%s) error: %s", deviceId, err)
+	} else if !resp.Success {
+		d.logger.Errorf("report aggregate of device(%s) failed: %s", deviceId, resp.ErrorMessage)
+	}
+}
@@ -0,0 +1,82 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/model"
+
+	pb_thingmodel "github.com/ytuox/elink-plugin-proto/thingmodel"
+)
+
+func TestSetAggregatePolicyTargets(t *testing.T) {
+	d, _ := newTestService(t)
+	tests := []struct {
+		name       string
+		identifier string
+		functions  map[model.AggregateFunc]string
+		wantErr    bool
+	}{
+		{"unknown property", "pressure", map[model.AggregateFunc]string{model.AggregateAvg: ""}, true},
+		{"unknown output", "temp", map[model.AggregateFunc]string{model.AggregateAvg: "temp_avg"}, true},
+		{"declared output", "temp", map[model.AggregateFunc]string{model.AggregateAvg: "", model.AggregateMax: "serial"}, false},
+		{"collides with other policy", "humidity", map[model.AggregateFunc]string{model.AggregateAvg: "serial"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := d.SetAggregatePolicy("p1", tt.identifier, model.NewAggregatePolicy(time.Minute, tt.functions))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetAggregatePolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPropertyAggregateReports(t *testing.T) {
+	d, core := newTestService(t)
+	window := 200 * time.Millisecond
+	if err := d.SetAggregatePolicy("p1", "temp", model.NewAggregatePolicy(window, map[model.AggregateFunc]string{model.AggregateMax: ""})); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for _, v := range []interface{}{10, 30, 20} {
+		if err := d.PropertyAggregate("d1", map[string]interface{}{"temp": v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.PropertyAggregate("d1", map[string]interface{}{"humidity": 50}); err != nil {
+		t.Fatal(err)
+	}
+
+	var reports []model.PropertyReport
+	waitFor(t, func() bool {
+		reports = reports[:0]
+		for _, up := range core.uplinks(pb_thingmodel.OperationType_PROPERTY_REPORT) {
+			var r model.PropertyReport
+			decodeUp(t, up, &r)
+			reports = append(reports, r)
+		}
+		return len(reports) == 2
+	})
+	if reports[0].Data["humidity"] != 50.0 {
+		t.Fatalf("first report = %v, want humidity passed through", reports[0].Data)
+	}
+	if got := reports[1]; got.Data["temp"] != 30.0 || got.Timestamp < start.Add(window).UnixMilli() || got.Timestamp > time.Now().UnixMilli() {
+		t.Fatalf("aggregate report = %+v, want max 30 at the window end", got)
+	}
+}
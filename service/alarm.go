@@ -0,0 +1,73 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"errors"
+
+	"github.com/ytuox/elink-sdk-go/internal/alarm"
+	"github.com/ytuox/elink-sdk-go/internal/validator"
+	"github.com/ytuox/elink-sdk-go/model"
+)
+
+// reportAlarms 上报告警触发与解除事件
+func (d *PluginService) reportAlarms(transitions []alarm.Transition) {
+	for _, t := range transitions {
+		event := t.Rule.RaiseEvent
+		if !t.Raise {
+			if t.Rule.ClearEvent == "" {
+				continue
+			}
+			event = t.Rule.ClearEvent
+		}
+
+		all := make(map[string]interface{}, len(t.Rule.Params)+2)
+		for k, v := range t.Rule.Params {
+			all[k] = v
+		}
+		if t.Rule.ValueParam != "" {
+			all[t.Rule.ValueParam] = t.Value
+		}
+		if t.Rule.ThresholdParam != "" {
+			all[t.Rule.ThresholdParam] = t.Rule.Threshold
+		}
+		params := all
+		if spec, ok := d.getProductEventByIdentifier(t.Rule.ProductId, event); ok {
+			// 触发与解除事件可以定义不同的参数,只携带本事件定义的参数
+			params = make(map[string]interface{}, len(spec.Params))
+			for _, io := range spec.Params {
+				if v, ok := all[io.Identifier]; ok {
+					params[io.Identifier] = v
+				}
+			}
+			if reasons := validator.CheckParams(spec.Params, params); len(reasons) > 0 {
+				// 参数与事件定义不符时重试也无法成功,保留告警状态
+				d.logger.Errorf("invalid alarm(%s) event(%s) of device(%s): %s", t.Rule.Id, event, t.DeviceId, validator.Message(reasons))
+				continue
+			}
+		}
+
+		data := model.NewEventData(event, params)
+		data.Timestamp = t.Timestamp
+		resp, err := d.eventReport(t.DeviceId, model.NewEventReport(data))
+		if err == nil && !resp.Success {
+			err = errors.New(resp.ErrorMessage)
+		}
+		if err != nil {
+			d.logger.Errorf("report alarm(%s) event(%s) of device(%s) error: %s", t.Rule.Id, event, t.DeviceId, err)
+			d.alarmEngine.Rollback(t)
+		}
+	}
+}
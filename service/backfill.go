@@ -0,0 +1,120 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ytuox/elink-sdk-go/model"
+	"github.com/ytuox/elink-sdk-go/util"
+)
+
+func (d *PluginService) propertyBackfill(deviceId string, samples map[string][]model.PropertyData) (model.BackfillResult, error) {
+	var result model.BackfillResult
+	if len(deviceId) == 0 {
+		return result, errors.New("required device id")
+	}
+
+	// 按采样时间分组,同一时刻的属性合并为一条消息
+	groups := make(map[int64]map[string]interface{})
+	for identifier, values := range samples {
+		for _, v := range values {
+			if v.Timestamp <= 0 {
+				return result, fmt.Errorf("property(%s) sample missing timestamp", identifier)
+			}
+			g, ok := groups[v.Timestamp]
+			if !ok {
+				g = make(map[string]interface{})
+				groups[v.Timestamp] = g
+			}
+			g[identifier] = v.Value
+		}
+	}
+	timestamps := make([]int64, 0, len(groups))
+	for ts := range groups {
+		timestamps = append(timestamps, ts)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	limit := d.limit()
+	for _, ts := range timestamps {
+		chunks, err := splitData(groups[ts], limit)
+		if err != nil {
+			return result, err
+		}
+		for _, chunk := range chunks {
+			report := model.NewPropertyReport("", ts, chunk)
+			report.Historical = true
+			resp, err := d.sendPropertyReport(deviceId, report)
+			if err != nil {
+				return result, err
+			}
+			if !resp.Success {
+				return result, fmt.Errorf("backfill rejected: %s", resp.ErrorMessage)
+			}
+			result.Messages++
+			result.Samples += len(chunk)
+		}
+	}
+	return result, nil
+}
+
+// limit 返回当前的上行消息大小限制
+func (d *PluginService) limit() model.UplinkLimit {
+	d.limitMu.RLock()
+	defer d.limitMu.RUnlock()
+	return d.uplinkLimit
+}
+
+// splitData 按单条消息的键个数与大小限制拆分数据
+func splitData[V any](data map[string]V, limit model.UplinkLimit) ([]map[string]V, error) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var (
+		chunks []map[string]V
+		chunk  = make(map[string]V)
+		size   = 2
+	)
+	for _, k := range keys {
+		item, err := util.ByteEncoder(map[string]V{k: data[k]})
+		if err != nil {
+			return nil, err
+		}
+		// 去掉外层的{},并计入分隔符
+		itemSize := len(item) - 1
+		if limit.MaxPayloadSize > 0 && itemSize+1 > limit.MaxPayloadSize {
+			return nil, fmt.Errorf("key(%s) exceeds max payload size %d", k, limit.MaxPayloadSize)
+		}
+		full := limit.MaxKeys > 0 && len(chunk) >= limit.MaxKeys
+		oversize := limit.MaxPayloadSize > 0 && size+itemSize > limit.MaxPayloadSize
+		if len(chunk) > 0 && (full || oversize) {
+			chunks = append(chunks, chunk)
+			chunk = make(map[string]V)
+			size = 2
+		}
+		chunk[k] = data[k]
+		size += itemSize
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
@@ -0,0 +1,143 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ytuox/elink-sdk-go/model"
+	"github.com/ytuox/elink-sdk-go/util"
+
+	pb_thingmodel "github.com/ytuox/elink-plugin-proto/thingmodel"
+)
+
+func TestSplitData(t *testing.T) {
+	data := map[string]int{"e": 5, "c": 3, "a": 1, "d": 4, "b": 2}
+	tests := []struct {
+		name    string
+		data    map[string]int
+		limit   model.UplinkLimit
+		want    []map[string]int
+		wantErr string
+	}{
+		{
+			name: "no limit",
+			data: data,
+			want: []map[string]int{data},
+		},
+		{
+			name:  "max keys",
+			data:  data,
+			limit: model.UplinkLimit{MaxKeys: 2},
+			want:  []map[string]int{{"a": 1, "b": 2}, {"c": 3, "d": 4}, {"e": 5}},
+		},
+		{
+			// {"a":1,"b":2} 为13字节,按上限估算为14字节
+			name:  "max payload size",
+			data:  data,
+			limit: model.UplinkLimit{MaxPayloadSize: 14},
+			want:  []map[string]int{{"a": 1, "b": 2}, {"c": 3, "d": 4}, {"e": 5}},
+		},
+		{
+			name:  "keys limit before size",
+			data:  data,
+			limit: model.UplinkLimit{MaxPayloadSize: 100, MaxKeys: 3},
+			want:  []map[string]int{{"a": 1, "b": 2, "c": 3}, {"d": 4, "e": 5}},
+		},
+		{
+			name:  "size limit before keys",
+			data:  data,
+			limit: model.UplinkLimit{MaxPayloadSize: 20, MaxKeys: 4},
+			want:  []map[string]int{{"a": 1, "b": 2, "c": 3}, {"d": 4, "e": 5}},
+		},
+		{
+			name:    "key exceeds max payload size",
+			data:    map[string]int{"a": 1, "long": 1000},
+			limit:   model.UplinkLimit{MaxPayloadSize: 10},
+			wantErr: "key(long) exceeds max payload size 10",
+		},
+		{
+			name: "empty",
+			data: map[string]int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitData(tt.data, tt.limit)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("splitData() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("splitData() = %v, want %v", got, tt.want)
+			}
+			for _, chunk := range got {
+				b, _ := util.ByteEncoder(chunk)
+				if tt.limit.MaxPayloadSize > 0 && len(b) > tt.limit.MaxPayloadSize {
+					t.Fatalf("chunk %s exceeds max payload size %d", b, tt.limit.MaxPayloadSize)
+				}
+			}
+		})
+	}
+}
+
+func TestPropertyBackfill(t *testing.T) {
+	d, core := newTestService(t)
+	d.SetUplinkLimit(model.UplinkLimit{MaxKeys: 1})
+	samples := map[string][]model.PropertyData{
+		"temp":     {{Value: 21, Timestamp: 2000}, {Value: 20, Timestamp: 1000}},
+		"humidity": {{Value: 50, Timestamp: 1000}},
+	}
+	result, err := d.PropertyBackfill("d1", samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Messages != 3 || result.Samples != 3 {
+		t.Fatalf("PropertyBackfill() = %+v, want 3 messages of 3 samples", result)
+	}
+
+	// 按采样时间先后上报,同一时刻按属性标识排序
+	want := []struct {
+		ts   int64
+		data map[string]interface{}
+	}{
+		{1000, map[string]interface{}{"humidity": float64(50)}},
+		{1000, map[string]interface{}{"temp": float64(20)}},
+		{2000, map[string]interface{}{"temp": float64(21)}},
+	}
+	ups := core.uplinks(pb_thingmodel.OperationType_PROPERTY_REPORT)
+	if len(ups) != len(want) {
+		t.Fatalf("property uplinks = %d, want %d", len(ups), len(want))
+	}
+	for i, up := range ups {
+		var got model.PropertyReport
+		decodeUp(t, up, &got)
+		if got.Timestamp != want[i].ts || !got.Historical || !reflect.DeepEqual(got.Data, want[i].data) {
+			t.Fatalf("uplink %d = %+v, want historical %v at %d", i, got, want[i].data, want[i].ts)
+		}
+	}
+
+	_, err = d.PropertyBackfill("d1", map[string][]model.PropertyData{"temp": {{Value: 1}}})
+	if err == nil || !strings.Contains(err.Error(), "missing timestamp") {
+		t.Fatalf("PropertyBackfill() error = %v, want missing timestamp", err)
+	}
+}
@@ -0,0 +1,103 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ytuox/elink-sdk-go/common"
+	"github.com/ytuox/elink-sdk-go/model"
+)
+
+const (
+	batchPropertyPrefix = "p:"
+	batchEventPrefix    = "e:"
+
+	// batchEnvelopeSize {"properties":{},"events":{}} 的长度
+	batchEnvelopeSize = 30
+)
+
+// batchReport 批量上报属性和事件,超出单条消息限制时拆分发送并汇总结果
+func (d *PluginService) batchReport(cid string, data model.BatchReport) (model.BatchReportResult, error) {
+	var result model.BatchReportResult
+	if len(cid) == 0 {
+		return result, errors.New("required device id")
+	}
+
+	chunks, err := splitBatch(data.Data, d.limit())
+	if err != nil {
+		return result, err
+	}
+	var messages []string
+	for i, chunk := range chunks {
+		report := data
+		report.Data = chunk
+		if i > 0 {
+			report.MsgId = ""
+		}
+		d.fillMsgId(&report.MsgId)
+		resp, err := d.reportUp(cid, common.BatchReport, report.MsgId, report.Sys, report)
+		if err != nil {
+			return result, err
+		}
+		result.Messages++
+		if !resp.Success {
+			result.Failed++
+			messages = append(messages, resp.ErrorMessage)
+		}
+	}
+	result.Success = result.Failed == 0
+	result.ErrorMessage = strings.Join(messages, "; ")
+	return result, nil
+}
+
+// splitBatch 属性与事件按同一限制拆分,同一条消息中可以同时包含属性和事件
+func splitBatch(data model.BatchData, limit model.UplinkLimit) ([]model.BatchData, error) {
+	items := make(map[string]interface{}, len(data.Properties)+len(data.Events))
+	for k, v := range data.Properties {
+		items[batchPropertyPrefix+k] = v
+	}
+	for k, v := range data.Events {
+		items[batchEventPrefix+k] = v
+	}
+	if limit.MaxPayloadSize > batchEnvelopeSize {
+		limit.MaxPayloadSize -= batchEnvelopeSize
+	}
+
+	parts, err := splitData(items, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		return []model.BatchData{data}, nil
+	}
+	chunks := make([]model.BatchData, 0, len(parts))
+	for _, part := range parts {
+		chunk := model.BatchData{
+			Properties: make(map[string]model.BatchProperty),
+			Events:     make(map[string]model.BatchEvent),
+		}
+		for k, v := range part {
+			if id, ok := strings.CutPrefix(k, batchPropertyPrefix); ok {
+				chunk.Properties[id] = v.(model.BatchProperty)
+			} else {
+				chunk.Events[strings.TrimPrefix(k, batchEventPrefix)] = v.(model.BatchEvent)
+			}
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
@@ -0,0 +1,148 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/ytuox/elink-sdk-go/model"
+
+	pb_common "github.com/ytuox/elink-plugin-proto/common"
+	pb_thingmodel "github.com/ytuox/elink-plugin-proto/thingmodel"
+)
+
+func batchData(properties []string, events []string) model.BatchData {
+	data := model.BatchData{
+		Properties: make(map[string]model.BatchProperty),
+		Events:     make(map[string]model.BatchEvent),
+	}
+	for _, id := range properties {
+		data.Properties[id] = model.BatchProperty{Value: 1}
+	}
+	for _, id := range events {
+		data.Events[id] = model.BatchEvent{OutputParams: map[string]interface{}{}}
+	}
+	return data
+}
+
+func TestSplitBatch(t *testing.T) {
+	// 每个属性按 "p:a":{"value":1}, 估算为18字节,外层另计30字节
+	data := batchData([]string{"a", "b", "c"}, nil)
+	tests := []struct {
+		name  string
+		data  model.BatchData
+		limit model.UplinkLimit
+		want  []model.BatchData
+	}{
+		{"no limit", data, model.UplinkLimit{}, []model.BatchData{data}},
+		{"keys at limit", data, model.UplinkLimit{MaxKeys: 3}, []model.BatchData{data}},
+		{"keys over limit", data, model.UplinkLimit{MaxKeys: 2}, []model.BatchData{
+			batchData([]string{"a", "b"}, nil),
+			batchData([]string{"c"}, nil),
+		}},
+		{"size at limit", data, model.UplinkLimit{MaxPayloadSize: 30 + 2 + 3*18}, []model.BatchData{data}},
+		{"size over limit", data, model.UplinkLimit{MaxPayloadSize: 30 + 2 + 3*18 - 1}, []model.BatchData{
+			batchData([]string{"a", "b"}, nil),
+			batchData([]string{"c"}, nil),
+		}},
+		// 事件排在属性之前,同一条消息可以同时包含属性和事件
+		{"properties and events", batchData([]string{"a", "b"}, []string{"x"}), model.UplinkLimit{MaxKeys: 2}, []model.BatchData{
+			batchData([]string{"a"}, []string{"x"}),
+			batchData([]string{"b"}, nil),
+		}},
+		{"empty", model.BatchData{}, model.UplinkLimit{MaxKeys: 1}, []model.BatchData{{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitBatch(tt.data, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("splitBatch() = %+v, want %+v", got, tt.want)
+			}
+			for _, chunk := range got {
+				b, _ := json.Marshal(chunk)
+				if tt.limit.MaxPayloadSize > 0 && len(b) > tt.limit.MaxPayloadSize {
+					t.Fatalf("chunk %s exceeds max payload size %d", b, tt.limit.MaxPayloadSize)
+				}
+			}
+		})
+	}
+
+	if _, err := splitBatch(data, model.UplinkLimit{MaxPayloadSize: 40}); err == nil {
+		t.Fatal("splitBatch() with an item over the limit succeeded")
+	}
+}
+
+func TestBatchReportChunks(t *testing.T) {
+	d, core := newTestService(t)
+	d.SetUplinkLimit(model.UplinkLimit{MaxKeys: 1})
+	core.setReply(func(up *pb_thingmodel.ThingModelMsgUpRequest) *pb_common.CommonResponse {
+		var r model.BatchReport
+		_ = json.Unmarshal([]byte(up.GetData()), &r)
+		if _, ok := r.Data.Properties["b"]; ok {
+			return &pb_common.CommonResponse{Success: false, Message: "b rejected"}
+		}
+		return &pb_common.CommonResponse{Success: true}
+	})
+
+	report := model.NewBatchReport(batchData([]string{"a", "b"}, []string{"x"}))
+	report.MsgId = "batch"
+	result, err := d.BatchReport("d1", report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Messages != 3 || result.Failed != 1 || result.Success || result.ErrorMessage != "b rejected" {
+		t.Fatalf("BatchReport() = %+v, want 3 messages with 1 rejected", result)
+	}
+
+	ups := core.uplinks(pb_thingmodel.OperationType_DATA_BATCH_REPORT)
+	if len(ups) != 3 {
+		t.Fatalf("batch uplinks = %d, want 3", len(ups))
+	}
+	want := []model.BatchData{
+		batchData(nil, []string{"x"}),
+		batchData([]string{"a"}, nil),
+		batchData([]string{"b"}, nil),
+	}
+	msgIds := make(map[string]bool)
+	for i, up := range ups {
+		var got model.BatchReport
+		decodeUp(t, up, &got)
+		if i == 0 && got.MsgId != "batch" {
+			t.Fatalf("first chunk msgId = %s, want the report's msgId", got.MsgId)
+		}
+		if got.MsgId == "" || msgIds[got.MsgId] {
+			t.Fatalf("chunk %d msgId = %q, want a unique msgId", i, got.MsgId)
+		}
+		msgIds[got.MsgId] = true
+		if len(got.Data.Properties) != len(want[i].Properties) || len(got.Data.Events) != len(want[i].Events) {
+			t.Fatalf("chunk %d = %+v, want %+v", i, got.Data, want[i])
+		}
+		for id := range want[i].Properties {
+			if _, ok := got.Data.Properties[id]; !ok {
+				t.Fatalf("chunk %d = %+v, want property %s", i, got.Data, id)
+			}
+		}
+		for id := range want[i].Events {
+			if _, ok := got.Data.Events[id]; !ok {
+				t.Fatalf("chunk %d = %+v, want event %s", i, got.Data, id)
+			}
+		}
+	}
+}
@@ -15,10 +15,13 @@
 package service
 
 import (
+	"time"
+
 	"github.com/ytuox/elink-sdk-go/common"
 	"github.com/ytuox/elink-sdk-go/interfaces"
 	"github.com/ytuox/elink-sdk-go/internal/logger"
 	"github.com/ytuox/elink-sdk-go/model"
+	"github.com/ytuox/elink-sdk-go/pkg/storage"
 )
 
 // Start 启动驱动
@@ -31,6 +34,42 @@ func (d *PluginService) Stop() error {
 	return d.stop()
 }
 
+// Use 注册下行消息中间件,按注册顺序由外向内执行
+func (d *PluginService) Use(mw ...interfaces.Middleware) {
+	d.chain.Use(mw...)
+}
+
+// SetAckTimeout 设置等待核心服务确认上行消息的超时时间,上报消息的Sys设为 model.AckRequired() 时等待确认
+func (d *PluginService) SetAckTimeout(timeout time.Duration) {
+	d.pending.SetTimeout(timeout)
+}
+
+// SetRetryPolicy 设置调用核心服务失败时的重试策略
+func (d *PluginService) SetRetryPolicy(policy model.RetryPolicy) {
+	d.rpcClient.SetRetryPolicy(policy)
+}
+
+// SetCircuitBreaker 设置调用核心服务的熔断策略,熔断期间调用立即失败
+func (d *PluginService) SetCircuitBreaker(policy model.CircuitBreakerPolicy) {
+	d.rpcClient.SetCircuitBreaker(policy)
+}
+
+// GetCircuitState 获取调用核心服务的熔断状态
+func (d *PluginService) GetCircuitState() model.CircuitState {
+	return d.rpcClient.CircuitState()
+}
+
+// EnableHA 启用主备模式,应在 Start 之前调用。只有主实例上报数据与处理下行消息,
+// 插件实现 interfaces.LeaderAware 接口后在角色变化时收到通知
+func (d *PluginService) EnableHA(policy model.HAPolicy) error {
+	return d.enableHA(policy)
+}
+
+// IsLeader 当前实例是否为主实例,未启用主备模式时始终返回true
+func (d *PluginService) IsLeader() bool {
+	return d.isLeader()
+}
+
 // GetLogger 获取日志接口
 func (d *PluginService) GetLogger() logger.Logger {
 	return d.logger
@@ -38,7 +77,18 @@ func (d *PluginService) GetLogger() logger.Logger {
 
 // GetCustomParam 获取自定义参数
 func (d *PluginService) GetPluginParam() string {
-	return d.cfg.PluginParam
+	return d.pluginParam()
+}
+
+// Params 将插件参数解码到结构体指针v,支持 default 标签设置默认值与 validate 标签校验,
+// 之后参数变化时以同样的类型通知实现了 interfaces.ParamsAware 的插件
+func (d *PluginService) Params(v interface{}) error {
+	return d.decodeParams(v)
+}
+
+// ReloadParams 使用新的JSON格式插件参数,如核心服务推送的参数,参数校验失败时保留原参数
+func (d *PluginService) ReloadParams(raw string) error {
+	return d.reloadParams(raw)
 }
 
 // Online 设备与平台建立连接
@@ -91,6 +141,29 @@ func (d *PluginService) GetDeviceServiceShadow(deviceId, identifier string) ([]m
 	return d.getDeviceServiceShadow(deviceId, identifier)
 }
 
+// DiffPropertyShadow 比较设备属性影子与设备孪生中的本地状态
+func (d *PluginService) DiffPropertyShadow(deviceId string) (model.ShadowDiff, error) {
+	return d.diffPropertyShadow(deviceId, nil)
+}
+
+// DiffPropertyShadowWith 比较设备属性影子与插件提供的本地状态
+func (d *PluginService) DiffPropertyShadowWith(deviceId string, local map[string]model.PropertyData) (model.ShadowDiff, error) {
+	if local == nil {
+		local = map[string]model.PropertyData{}
+	}
+	return d.diffPropertyShadow(deviceId, local)
+}
+
+// ReportShadowDiff 将与影子不一致的本地属性值重新上报到平台
+func (d *PluginService) ReportShadowDiff(deviceId string, diff model.ShadowDiff) (model.CommonResponse, error) {
+	return d.reportShadowDiff(deviceId, diff)
+}
+
+// ApplyShadowDiff 将影子中与本地不同的属性值重新下发给设备
+func (d *PluginService) ApplyShadowDiff(deviceId string, diff model.ShadowDiff) error {
+	return d.applyShadowDiff(deviceId, diff)
+}
+
 // ProductList 获取当前实例下的所有产品
 func (d *PluginService) ProductList() map[string]model.Product {
 	return d.productCache.All()
@@ -136,29 +209,150 @@ func (d *PluginService) PropertyReport(deviceId string, data model.PropertyRepor
 	return d.propertyReport(deviceId, data)
 }
 
-// EventReport 物模型事件上报
+// PropertyAggregate 提交原始属性值,配置了聚合策略的属性在窗口结束时以统计值上报,其余属性直接上报
+func (d *PluginService) PropertyAggregate(deviceId string, data map[string]interface{}) error {
+	return d.propertyAggregate(deviceId, data)
+}
+
+// SetAggregatePolicy 设置产品属性的聚合策略
+func (d *PluginService) SetAggregatePolicy(productId, identifier string, policy model.AggregatePolicy) error {
+	return d.setAggregatePolicy(productId, identifier, policy)
+}
+
+// RemoveAggregatePolicy 删除产品属性的聚合策略
+func (d *PluginService) RemoveAggregatePolicy(productId, identifier string) {
+	d.aggregator.RemovePolicy(productId, identifier)
+}
+
+// AddAlarmRule 添加本地阈值告警规则,PropertyReport 上报的属性满足条件时自动上报告警事件
+func (d *PluginService) AddAlarmRule(rule model.AlarmRule) error {
+	return d.alarmEngine.AddRule(rule)
+}
+
+// RemoveAlarmRule 删除本地阈值告警规则
+func (d *PluginService) RemoveAlarmRule(ruleId string) {
+	d.alarmEngine.RemoveRule(ruleId)
+}
+
+// GetActiveAlarms 获取设备当前未解除的告警
+func (d *PluginService) GetActiveAlarms(deviceId string) []model.AlarmState {
+	return d.alarmEngine.Active(deviceId)
+}
+
+// PropertyBackfill 补传设备离线期间记录的历史属性数据,按原始采样时间拆分为多条上报消息,
+// 消息标记为历史数据且不经过变化上报过滤
+func (d *PluginService) PropertyBackfill(deviceId string, samples map[string][]model.PropertyData) (model.BackfillResult, error) {
+	return d.propertyBackfill(deviceId, samples)
+}
+
+// SetUplinkLimit 设置单条上行消息的大小限制
+func (d *PluginService) SetUplinkLimit(limit model.UplinkLimit) {
+	d.limitMu.Lock()
+	defer d.limitMu.Unlock()
+	d.uplinkLimit = limit
+}
+
+// SetRateLimitPolicy 启用上行消息限流,事件与下行命令的应答优先于属性上报发送
+func (d *PluginService) SetRateLimitPolicy(policy model.RateLimitPolicy) {
+	d.uplink.SetPolicy(policy)
+}
+
+// DisableRateLimit 关闭上行消息限流
+func (d *PluginService) DisableRateLimit() {
+	d.uplink.Disable()
+}
+
+// GetUplinkStats 获取上行消息的发送、丢弃与延迟计数
+func (d *PluginService) GetUplinkStats() model.UplinkStats {
+	return d.uplink.Stats()
+}
+
+// EventReport 物模型事件上报 如果data参数中的Sys.Ack设置为1，则该方法会同步阻塞等待云端返回结果。
 func (d *PluginService) EventReport(deviceId string, data model.EventReport) (model.CommonResponse, error) {
 	return d.eventReport(deviceId, data)
 }
 
 // BatchReport 设备批量上报属性和事件 如果data参数中的Sys.Ack设置为1，则该方法会同步阻塞等待云端返回结果。
 // 如非必要，不建议设置Sys.Ack
-// 废弃
-// func (d *PluginService) BatchReport(deviceId string, data model.BatchReport) (model.CommonResponse, error) {
-// 	return d.batchReport(deviceId, data)
-// }
-
-// PropertyDesiredGet 设备拉取属性期望值 如果data参数中的Sys.Ack设置为1，则该方法会同步阻塞等待云端返回结果。
-// 废弃
-//func (d *PluginService) PropertyDesiredGet(deviceId string, data model.PropertyDesiredGet) (model.PropertyDesiredGetResponse, error) {
-//	return d.propertyDesiredGet(deviceId, data)
-//}
-
-// PropertyDesiredDelete 设备删除属性期望值 如果data参数中的Sys.Ack设置为1，则该方法会同步阻塞等待云端返回结果。
-// 废弃
-//func (d *PluginService) PropertyDesiredDelete(deviceId string, data model.PropertyDesiredDelete) (model.PropertyDesiredDeleteResponse, error) {
-//	return d.propertyDesiredDelete(deviceId, data)
-//}
+// 数据超出 SetUplinkLimit 设置的限制时自动拆分为多条消息发送,返回汇总结果
+func (d *PluginService) BatchReport(deviceId string, data model.BatchReport) (model.BatchReportResult, error) {
+	return d.batchReport(deviceId, data)
+}
+
+// PropertyDesiredGet 设备拉取属性期望值,同步阻塞等待云端返回结果,超时时间由 SetAckTimeout 设置
+func (d *PluginService) PropertyDesiredGet(deviceId string, data model.PropertyDesiredGet) (model.PropertyDesiredGetResponse, error) {
+	return d.propertyDesiredGet(deviceId, data)
+}
+
+// PropertyDesiredDelete 设备删除属性期望值,同步阻塞等待云端返回结果,超时时间由 SetAckTimeout 设置
+func (d *PluginService) PropertyDesiredDelete(deviceId string, data model.PropertyDesiredDelete) (model.PropertyDesiredDeleteResponse, error) {
+	return d.propertyDesiredDelete(deviceId, data)
+}
+
+// ReconcileDesired 将云端缓存的属性期望值通过 HandlePropertySet 下发给设备,
+// 插件以同一MsgId调用 PropertySetResponse 应答成功后清除期望值
+func (d *PluginService) ReconcileDesired(deviceId string) error {
+	return d.reconcileDesired(deviceId)
+}
+
+// SetDesiredReconcile 设置设备上线(ConnectIotPlatform)后是否自动下发属性期望值
+func (d *PluginService) SetDesiredReconcile(enabled bool) {
+	d.desiredReconcile.Store(enabled)
+}
+
+// GetDeviceTwin 获取设备孪生中保存的最近一次上报的属性值
+func (d *PluginService) GetDeviceTwin(deviceId string) map[string]model.PropertyData {
+	return d.twinCache.All(deviceId)
+}
+
+// SetTwinPolicy 设置产品的设备孪生应答策略,属性值未过期时由SDK直接应答属性查询,不再调用HandlePropertyGet
+func (d *PluginService) SetTwinPolicy(productId string, policy model.TwinPolicy) error {
+	return d.setTwinPolicy(productId, policy)
+}
+
+// RemoveTwinPolicy 删除产品的设备孪生应答策略
+func (d *PluginService) RemoveTwinPolicy(productId string) {
+	d.twinCache.RemovePolicy(productId)
+}
+
+// SetReportFilterPolicy 设置产品的变化上报策略,PropertyReport 仅上报发生变化或超出死区的属性
+func (d *PluginService) SetReportFilterPolicy(productId string, policy model.ReportFilterPolicy) error {
+	return d.setReportFilterPolicy(productId, policy)
+}
+
+// RemoveReportFilterPolicy 删除产品的变化上报策略
+func (d *PluginService) RemoveReportFilterPolicy(productId string) {
+	d.reportFilter.RemovePolicy(productId)
+}
+
+// SetPropertySetValidation 设置属性下发的校验模式,严格模式下对只读、未定义或不符合物模型定义的属性
+// 由SDK直接应答失败,不再调用HandlePropertySet
+func (d *PluginService) SetPropertySetValidation(mode model.ValidateMode) {
+	d.validator.SetPropertySetMode(mode)
+}
+
+// SetServiceValidation 设置服务调用输入输出参数的校验模式,严格模式下输入参数不合法时调用失败,
+// 输出参数不合法时 ServiceExecuteResponse 返回错误且不上报
+func (d *PluginService) SetServiceValidation(mode model.ValidateMode) {
+	d.validator.SetServiceMode(mode)
+}
+
+// SetDispatchPolicy 启用下行命令队列,同一设备(或同一KeyFunc键)的属性下发、查询与服务调用串行执行,
+// 删除设备时取消其未完成的命令
+func (d *PluginService) SetDispatchPolicy(policy model.DispatchPolicy) {
+	d.dispatcher.SetPolicy(policy)
+}
+
+// DisableDispatch 关闭下行命令队列,下行命令并发调用插件
+func (d *PluginService) DisableDispatch() {
+	d.dispatcher.Disable()
+}
+
+// SetDedupPolicy 设置下行消息去重策略,时间窗口内重复的属性下发与服务调用不再调用插件,
+// 已应答的消息重发之前的应答
+func (d *PluginService) SetDedupPolicy(policy model.DedupPolicy) {
+	d.dedup.SetPolicy(policy)
+}
 
 // PropertySetResponse 设备属性下发响应
 func (d *PluginService) PropertySetResponse(deviceId string, data model.PropertySetResponse) error {
@@ -195,6 +389,23 @@ func (d *PluginService) GetAllCustomStorage() (map[string][]byte, error) {
 	return d.getAllCustomStorage()
 }
 
+// EnableStorageCache 启用自定义存储的本地缓存,写入与删除同步到核心服务后更新缓存,
+// maxEntries 为缓存的最大条数,应在 Start 之前调用
+func (d *PluginService) EnableStorageCache(maxEntries int) error {
+	return d.enableStorageCache(maxEntries)
+}
+
+// Storage 获取带命名空间与版本管理的JSON存储
+func (d *PluginService) Storage() *storage.Store {
+	return d.store
+}
+
+// SecureStorage 获取加密的JSON存储,数据保存在 secure/ 命名空间下,密钥由配置中的 Storage.Secret 派生,
+// 旧密钥加密的数据在读取时使用当前密钥重新加密,明文数据可以直接读取
+func (d *PluginService) SecureStorage() (*storage.Store, error) {
+	return d.secureStorage()
+}
+
 // GetAllCustomStorage 获取所有驱动存储的自定义内容
 func (d *PluginService) AppSendCommand(deviceId, serviceId, data string) error {
 	return d.appSendCommandRequest(deviceId, serviceId, data)
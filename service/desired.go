@@ -0,0 +1,209 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/common"
+	"github.com/ytuox/elink-sdk-go/model"
+)
+
+// desiredResponseTimeout 等待插件应答期望值下发的最长时间,超时后不再清除期望值
+const desiredResponseTimeout = 10 * time.Minute
+
+// desiredSet 已下发、等待插件应答的期望值版本
+type desiredSet struct {
+	deviceId string
+	versions map[string]model.PropertyDesiredDeleteValue
+}
+
+func (d *PluginService) propertyDesiredGet(deviceId string, data model.PropertyDesiredGet) (model.PropertyDesiredGetResponse, error) {
+	var result model.PropertyDesiredGetResponse
+	d.fillMsgId(&data.MsgId)
+	payload, err := d.requestUp(deviceId, common.PropertyDesiredGet, data.MsgId, data)
+	if err != nil {
+		return result, err
+	}
+	if result.CommonResponse, err = decodeAck(payload); err != nil {
+		return result, err
+	}
+	result.Data, err = decodeDesired(payload)
+	return result, err
+}
+
+func (d *PluginService) propertyDesiredDelete(deviceId string, data model.PropertyDesiredDelete) (model.PropertyDesiredDeleteResponse, error) {
+	var result model.PropertyDesiredDeleteResponse
+	d.fillMsgId(&data.MsgId)
+	payload, err := d.requestUp(deviceId, common.PropertyDesiredDelete, data.MsgId, data)
+	if err != nil {
+		return result, err
+	}
+	if result.CommonResponse, err = decodeAck(payload); err != nil {
+		return result, err
+	}
+	result.Data, err = decodeDesired(payload)
+	return result, err
+}
+
+// requestUp 发送需要核心服务应答的上行请求,返回应答内容
+func (d *PluginService) requestUp(cid string, t int, msgId string, data interface{}) (string, error) {
+	mac := d.pending.Add(cid, msgId)
+	resp, err := d.thingModelMsgUp(cid, t, data)
+	if err != nil {
+		d.pending.Cancel(mac)
+		return "", err
+	}
+	if !resp.GetSuccess() {
+		d.pending.Cancel(mac)
+		return "", errors.New(resp.GetMessage())
+	}
+	payload, err := d.pending.Wait(mac)
+	if err != nil {
+		return "", fmt.Errorf("message(%s): %w", msgId, err)
+	}
+	return payload, nil
+}
+
+// decodeDesired 解析应答中的属性期望值及其版本
+func decodeDesired(payload string) (map[string]model.PropertyDesiredGetValue, error) {
+	var resp struct {
+		Data map[string]model.PropertyDesiredGetValue `json:"data"`
+	}
+	dec := json.NewDecoder(strings.NewReader(payload))
+	dec.UseNumber()
+	if err := dec.Decode(&resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// reconcileDesired 下发设备可写属性的期望值,插件通过 PropertySetResponse 应答成功后按版本清除期望值
+func (d *PluginService) reconcileDesired(deviceId string) error {
+	if d.rpcServer == nil {
+		return errors.New("plugin service not started")
+	}
+	device, ok := d.deviceCache.SearchById(deviceId)
+	if !ok {
+		return fmt.Errorf("device(%s) not found", deviceId)
+	}
+	properties, _ := d.productCache.GetProductProperties(device.ProductId)
+	identifiers := make([]string, 0, len(properties))
+	for id, p := range properties {
+		if !p.ReadOnly() {
+			identifiers = append(identifiers, id)
+		}
+	}
+	if len(identifiers) == 0 {
+		return nil
+	}
+
+	get := model.NewPropertyDesiredGet(identifiers)
+	get.Timestamp = d.now().UnixMilli()
+	desired, err := d.propertyDesiredGet(deviceId, get)
+	if err != nil {
+		return err
+	}
+	if !desired.Success {
+		return fmt.Errorf("get desired properties error: %s", desired.ErrorMessage)
+	}
+	if len(desired.Data) == 0 {
+		return nil
+	}
+
+	req := model.PropertySet{
+		CommonRequest: model.CommonRequest{
+			Version:   model.Version,
+			Timestamp: d.now().UnixMilli(),
+		},
+		Data: make(map[string]interface{}, len(desired.Data)),
+		Spec: make(map[string]model.Property, len(desired.Data)),
+	}
+	d.fillMsgId(&req.MsgId)
+	versions := make(map[string]model.PropertyDesiredDeleteValue, len(desired.Data))
+	for id, v := range desired.Data {
+		req.Data[id] = v.Value
+		req.Spec[id] = properties[id]
+		versions[id] = model.PropertyDesiredDeleteValue{Version: v.Version}
+	}
+	// HandlePropertySet 返回nil只表示插件已接收,设备的执行结果由 PropertySetResponse 应答
+	d.addDesiredSet(req.MsgId, desiredSet{deviceId: deviceId, versions: versions})
+	if err = d.rpcServer.HandleDownlink(d.ctx, &model.DownlinkMessage{
+		Type:        model.DownlinkPropertySet,
+		Device:      device,
+		PropertySet: &req,
+	}); err != nil {
+		d.takeDesiredSet(deviceId, req.MsgId)
+		return fmt.Errorf("apply desired properties error: %w", err)
+	}
+	return nil
+}
+
+func (d *PluginService) addDesiredSet(msgId string, set desiredSet) {
+	d.desiredMu.Lock()
+	defer d.desiredMu.Unlock()
+	if d.desiredSets == nil {
+		d.desiredSets = make(map[string]desiredSet)
+	}
+	d.desiredSets[msgId] = set
+	time.AfterFunc(desiredResponseTimeout, func() {
+		d.takeDesiredSet(set.deviceId, msgId)
+	})
+}
+
+// takeDesiredSet 取出设备等待应答的期望值,其他设备相同MsgId的应答不影响等待
+func (d *PluginService) takeDesiredSet(deviceId, msgId string) (desiredSet, bool) {
+	d.desiredMu.Lock()
+	defer d.desiredMu.Unlock()
+	set, ok := d.desiredSets[msgId]
+	if !ok || set.deviceId != deviceId {
+		return desiredSet{}, false
+	}
+	delete(d.desiredSets, msgId)
+	return set, true
+}
+
+// desiredResponse 期望值下发应答成功后按版本清除期望值
+func (d *PluginService) desiredResponse(deviceId string, data model.PropertySetResponse) {
+	set, ok := d.takeDesiredSet(deviceId, data.MsgId)
+	if !ok {
+		return
+	}
+	if !data.Data.Success {
+		d.logger.Warnf("apply desired properties of device(%s) failed: %s", deviceId, data.Data.ErrorMessage)
+		return
+	}
+	go func() {
+		req := model.NewPropertyDesiredDelete(set.versions)
+		req.Timestamp = d.now().UnixMilli()
+		resp, err := d.propertyDesiredDelete(deviceId, req)
+		if err == nil && !resp.Success {
+			err = errors.New(resp.ErrorMessage)
+		}
+		if err != nil {
+			d.logger.Errorf("delete desired properties of device(%s) error: %s", deviceId, err)
+		}
+	}()
+}
+
+func (d *PluginService) reconcileOnline(deviceId string) {
+	if err := d.reconcileDesired(deviceId); err != nil {
+		d.logger.Errorf("reconcile desired properties of device(%s) error: %s", deviceId, err)
+	}
+}
@@ -0,0 +1,176 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/ytuox/elink-sdk-go/model"
+
+	pb_common "github.com/ytuox/elink-plugin-proto/common"
+	pb_thingmodel "github.com/ytuox/elink-plugin-proto/thingmodel"
+)
+
+// fakeDesired 模拟核心服务保存的属性期望值,按版本清除
+type fakeDesired struct {
+	mu     sync.Mutex
+	values map[string]model.PropertyDesiredGetValue
+}
+
+// serve 应答期望值的查询与清除请求
+func (f *fakeDesired) serve(t *testing.T, d *PluginService, core *fakeCore) {
+	core.setReply(func(up *pb_thingmodel.ThingModelMsgUpRequest) *pb_common.CommonResponse {
+		var (
+			op   pb_thingmodel.OperationType
+			resp = map[string]interface{}{"success": true}
+		)
+		switch up.GetOperationType() {
+		case pb_thingmodel.OperationType_PROPERTY_DESIRED_GET:
+			var req model.PropertyDesiredGet
+			_ = json.Unmarshal([]byte(up.GetData()), &req)
+			data := make(map[string]model.PropertyDesiredGetValue)
+			f.mu.Lock()
+			for _, id := range req.Data {
+				if v, ok := f.values[id]; ok {
+					data[id] = v
+				}
+			}
+			f.mu.Unlock()
+			op, resp["msgId"], resp["data"] = pb_thingmodel.OperationType_PROPERTY_DESIRED_GET_RESPONSE, req.MsgId, data
+		case pb_thingmodel.OperationType_PROPERTY_DESIRED_DELETE:
+			var req model.PropertyDesiredDelete
+			_ = json.Unmarshal([]byte(up.GetData()), &req)
+			f.mu.Lock()
+			for id, v := range req.Data {
+				if f.values[id].Version == v.Version {
+					delete(f.values, id)
+				}
+			}
+			f.mu.Unlock()
+			op, resp["msgId"] = pb_thingmodel.OperationType_PROPERTY_DESIRED_DELETE_RESPONSE, req.MsgId
+		default:
+			return &pb_common.CommonResponse{Success: true}
+		}
+		go func() {
+			if err := down(d, up.GetDeviceId(), op, resp); err != nil {
+				t.Error(err)
+			}
+		}()
+		return &pb_common.CommonResponse{Success: true}
+	})
+}
+
+func (f *fakeDesired) len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.values)
+}
+
+func TestPropertyDesiredGetAndDelete(t *testing.T) {
+	d, core := newTestService(t)
+	withPlugin(t, d)
+	desired := &fakeDesired{values: map[string]model.PropertyDesiredGetValue{
+		"temp":     {Value: 30, Version: 3},
+		"humidity": {Value: 40, Version: 1},
+	}}
+	desired.serve(t, d, core)
+
+	got, err := d.PropertyDesiredGet("d1", model.NewPropertyDesiredGet([]string{"temp", "serial"}))
+	if err != nil || !got.Success {
+		t.Fatalf("PropertyDesiredGet() = %+v, %v", got, err)
+	}
+	want := map[string]model.PropertyDesiredGetValue{"temp": {Value: json.Number("30"), Version: 3}}
+	if !reflect.DeepEqual(got.Data, want) {
+		t.Fatalf("PropertyDesiredGet() data = %+v, want %+v", got.Data, want)
+	}
+
+	// 版本不一致的期望值不会被清除
+	del := model.NewPropertyDesiredDelete(map[string]model.PropertyDesiredDeleteValue{"temp": {Version: 3}, "humidity": {Version: 0}})
+	if resp, err := d.PropertyDesiredDelete("d1", del); err != nil || !resp.Success {
+		t.Fatalf("PropertyDesiredDelete() = %+v, %v", resp, err)
+	}
+	if n := desired.len(); n != 1 {
+		t.Fatalf("desired values = %d, want humidity left", n)
+	}
+}
+
+func TestReconcileDesired(t *testing.T) {
+	d, core := newTestService(t)
+	if err := d.ReconcileDesired("d1"); err == nil {
+		t.Fatal("ReconcileDesired() before start succeeded")
+	}
+	plugin := withPlugin(t, d)
+	desired := &fakeDesired{values: map[string]model.PropertyDesiredGetValue{
+		"temp": {Value: 30, Version: 3},
+	}}
+	desired.serve(t, d, core)
+
+	if err := d.ReconcileDesired("d1"); err != nil {
+		t.Fatal(err)
+	}
+	// 只查询可写属性的期望值
+	var get model.PropertyDesiredGet
+	decodeUp(t, core.uplinks(pb_thingmodel.OperationType_PROPERTY_DESIRED_GET)[0], &get)
+	if len(get.Data) != 2 || get.Data[0] == "serial" || get.Data[1] == "serial" {
+		t.Fatalf("desired get identifiers = %v, want writable properties", get.Data)
+	}
+	plugin.mu.Lock()
+	if len(plugin.sets) != 1 || plugin.sets[0].Data["temp"] != json.Number("30") || plugin.sets[0].Spec["temp"].Identifier != "temp" {
+		plugin.mu.Unlock()
+		t.Fatalf("plugin property sets = %+v, want desired temp 30", plugin.sets)
+	}
+	set := plugin.sets[0]
+	plugin.mu.Unlock()
+
+	// 应答失败或其他设备的应答不清除期望值
+	fail := model.NewPropertySetResponse(set.MsgId, model.PropertySetResponseData{Success: false, ErrorMessage: "busy"})
+	if err := d.PropertySetResponse("d1", fail); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ReconcileDesired("d1"); err != nil {
+		t.Fatal(err)
+	}
+	plugin.mu.Lock()
+	set = plugin.sets[len(plugin.sets)-1]
+	plugin.mu.Unlock()
+	ok := model.NewPropertySetResponse(set.MsgId, model.PropertySetResponseData{Success: true})
+	if err := d.PropertySetResponse("d2", ok); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(core.uplinks(pb_thingmodel.OperationType_PROPERTY_DESIRED_DELETE)); n != 0 {
+		t.Fatalf("desired deletes = %d, want none before a successful response", n)
+	}
+
+	if err := d.PropertySetResponse("d1", ok); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return desired.len() == 0 })
+	var del model.PropertyDesiredDelete
+	decodeUp(t, core.uplinks(pb_thingmodel.OperationType_PROPERTY_DESIRED_DELETE)[0], &del)
+	if !reflect.DeepEqual(del.Data, map[string]model.PropertyDesiredDeleteValue{"temp": {Version: 3}}) {
+		t.Fatalf("desired delete = %+v, want the applied version", del.Data)
+	}
+
+	// 没有期望值时不调用插件
+	if err := d.ReconcileDesired("d1"); err != nil {
+		t.Fatal(err)
+	}
+	if sets, _, _ := plugin.calls(); sets != 2 {
+		t.Fatalf("plugin got %d property sets, want no set without desired values", sets)
+	}
+}
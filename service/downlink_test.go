@@ -0,0 +1,218 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/model"
+
+	pb_device "github.com/ytuox/elink-plugin-proto/device"
+	pb_device_callback "github.com/ytuox/elink-plugin-proto/devicecallback"
+	pb_thingmodel "github.com/ytuox/elink-plugin-proto/thingmodel"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDedupClearedOnDeviceDelete(t *testing.T) {
+	d, _ := newTestService(t)
+	plugin := withPlugin(t, d)
+	d.SetDedupPolicy(model.DedupPolicy{Window: time.Minute})
+
+	set := model.PropertySet{CommonRequest: model.CommonRequest{MsgId: "m1"}, Data: map[string]interface{}{"temp": 20}}
+	for i := 0; i < 2; i++ {
+		if err := down(d, "d1", pb_thingmodel.OperationType_PROPERTY_SET, set); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sets, _, _ := plugin.calls(); sets != 1 {
+		t.Fatalf("plugin got %d property sets, want duplicate dropped", sets)
+	}
+
+	// 删除后重新添加同id的设备,之前的MsgId不再视为重复
+	ctx := context.Background()
+	if _, err := d.rpcServer.DeleteDeviceCallback(ctx, &pb_device_callback.DeleteDeviceCallbackRequest{DeviceId: "d1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.rpcServer.CreateDeviceCallback(ctx, &pb_device_callback.CreateDeviceCallbackRequest{
+		Data: &pb_device.Device{Id: "d1", ProductId: "p1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := down(d, "d1", pb_thingmodel.OperationType_PROPERTY_SET, set); err != nil {
+		t.Fatal(err)
+	}
+	if sets, _, _ := plugin.calls(); sets != 2 {
+		t.Fatalf("plugin got %d property sets, want the re-added device's message delivered", sets)
+	}
+}
+
+func TestInvalidPropertySetRejectedBeforePlugin(t *testing.T) {
+	tests := []struct {
+		name string
+		data map[string]interface{}
+		want string
+	}{
+		{"unknown", map[string]interface{}{"color": "red"}, "color: unknown property"},
+		{"read-only", map[string]interface{}{"serial": "s1"}, "serial: property is read-only"},
+		{"out of range", map[string]interface{}{"temp": 120}, "temp: 120 is greater than max 100"},
+		{"wrong type", map[string]interface{}{"temp": "hot"}, "temp: hot is not a number"},
+		{"partly invalid", map[string]interface{}{"temp": 20, "serial": "s1"}, "serial: property is read-only"},
+	}
+	d, core := newTestService(t)
+	plugin := withPlugin(t, d)
+	d.SetPropertySetValidation(model.ValidateStrict)
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := model.PropertySet{CommonRequest: model.CommonRequest{MsgId: tt.name}, Data: tt.data}
+			if err := down(d, "d1", pb_thingmodel.OperationType_PROPERTY_SET, set); err != nil {
+				t.Fatal(err)
+			}
+			if sets, _, _ := plugin.calls(); sets != 0 {
+				t.Fatalf("plugin got %d property sets, want invalid payload rejected", sets)
+			}
+			ups := core.uplinks(pb_thingmodel.OperationType_PROPERTY_SET_RESPONSE)
+			if len(ups) != i+1 {
+				t.Fatalf("property set responses = %d, want %d", len(ups), i+1)
+			}
+			var resp model.PropertySetResponse
+			decodeUp(t, ups[i], &resp)
+			if resp.MsgId != tt.name || resp.Data.Success || resp.Data.Code != model.CodeInvalidParam || resp.Data.ErrorMessage != tt.want {
+				t.Fatalf("property set response = %+v, want failure %q", resp, tt.want)
+			}
+		})
+	}
+}
+
+func TestPropertySetValidationModes(t *testing.T) {
+	set := model.PropertySet{CommonRequest: model.CommonRequest{MsgId: "m1"}, Data: map[string]interface{}{"serial": "s1"}}
+	for _, mode := range []model.ValidateMode{model.ValidateOff, model.ValidateLenient} {
+		d, core := newTestService(t)
+		plugin := withPlugin(t, d)
+		d.SetPropertySetValidation(mode)
+		if err := down(d, "d1", pb_thingmodel.OperationType_PROPERTY_SET, set); err != nil {
+			t.Fatal(err)
+		}
+		if sets, _, _ := plugin.calls(); sets != 1 {
+			t.Fatalf("mode %d: plugin got %d property sets, want 1", mode, sets)
+		}
+		if n := len(core.uplinks(pb_thingmodel.OperationType_PROPERTY_SET_RESPONSE)); n != 0 {
+			t.Fatalf("mode %d: property set responses = %d, want none from the SDK", mode, n)
+		}
+	}
+
+	d, _ := newTestService(t)
+	plugin := withPlugin(t, d)
+	d.SetPropertySetValidation(model.ValidateStrict)
+	valid := model.PropertySet{CommonRequest: model.CommonRequest{MsgId: "m2"}, Data: map[string]interface{}{"temp": 20}}
+	if err := down(d, "d1", pb_thingmodel.OperationType_PROPERTY_SET, valid); err != nil {
+		t.Fatal(err)
+	}
+	plugin.mu.Lock()
+	defer plugin.mu.Unlock()
+	if len(plugin.sets) != 1 || plugin.sets[0].MsgId != "m2" || plugin.sets[0].Spec["temp"].Identifier != "temp" {
+		t.Fatalf("plugin property sets = %+v, want valid payload with spec", plugin.sets)
+	}
+}
+
+// execute 下发服务调用
+func execute(d *PluginService, msgId, serviceId string, input map[string]interface{}) error {
+	req := model.ServiceExecuteRequest{
+		CommonRequest: model.CommonRequest{MsgId: msgId},
+		Data:          model.ServiceDataIn{ServiceId: serviceId, Input: input},
+	}
+	return down(d, "d1", pb_thingmodel.OperationType_SERVICE_EXECUTE, req)
+}
+
+func TestServiceInputValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		serviceId string
+		input     map[string]interface{}
+		want      string
+	}{
+		{"valid", "reboot", map[string]interface{}{"delay": 10}, ""},
+		{"missing", "reboot", map[string]interface{}{}, "delay: missing parameter"},
+		{"extra", "reboot", map[string]interface{}{"delay": 10, "force": true}, "force: unknown parameter"},
+		{"wrong type", "reboot", map[string]interface{}{"delay": "soon"}, "delay: soon is not a number"},
+		{"out of range", "reboot", map[string]interface{}{"delay": 90}, "delay: 90 is greater than max 60"},
+		{"unknown service", "reset", nil, "unknown service"},
+	}
+	for _, mode := range []model.ValidateMode{model.ValidateStrict, model.ValidateLenient} {
+		d, _ := newTestService(t)
+		plugin := withPlugin(t, d)
+		d.SetServiceValidation(mode)
+
+		for _, tt := range tests {
+			_, _, before := plugin.calls()
+			err := execute(d, tt.name, tt.serviceId, tt.input)
+			_, _, after := plugin.calls()
+
+			if mode == model.ValidateLenient || tt.want == "" {
+				if err != nil || after != before+1 {
+					t.Fatalf("mode %d %s: error = %v, plugin calls %d, want passed to plugin", mode, tt.name, err, after-before)
+				}
+				continue
+			}
+			if status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("mode %d %s: error = %v, want InvalidArgument with %q", mode, tt.name, err, tt.want)
+			}
+			if after != before {
+				t.Fatalf("mode %d %s: plugin called for invalid input", mode, tt.name)
+			}
+		}
+	}
+}
+
+func TestServiceOutputValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		out  model.ServiceDataOut
+		want string
+	}{
+		{"valid", model.ServiceDataOut{ServiceId: "reboot", Output: map[string]interface{}{"ok": true}}, ""},
+		{"missing", model.ServiceDataOut{ServiceId: "reboot"}, "ok: missing parameter"},
+		{"extra", model.ServiceDataOut{ServiceId: "reboot", Output: map[string]interface{}{"ok": true, "code": 1}}, "code: unknown parameter"},
+		{"wrong type", model.ServiceDataOut{ServiceId: "reboot", Output: map[string]interface{}{"ok": "yes"}}, "ok: yes is not a bool"},
+		{"unknown service", model.ServiceDataOut{ServiceId: "reset"}, "unknown service"},
+	}
+	for _, mode := range []model.ValidateMode{model.ValidateStrict, model.ValidateLenient, model.ValidateOff} {
+		d, core := newTestService(t)
+		d.SetServiceValidation(mode)
+
+		for _, tt := range tests {
+			before := len(core.uplinks(pb_thingmodel.OperationType_SERVICE_EXECUTE_RESPONSE))
+			err := d.ServiceExecuteResponse("d1", model.NewServiceExecuteResponse(tt.name, tt.out))
+			after := len(core.uplinks(pb_thingmodel.OperationType_SERVICE_EXECUTE_RESPONSE))
+
+			if mode != model.ValidateStrict || tt.want == "" {
+				if err != nil || after != before+1 {
+					t.Fatalf("mode %d %s: error = %v, uplinks %d, want response sent", mode, tt.name, err, after-before)
+				}
+				continue
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("mode %d %s: error = %v, want %q", mode, tt.name, err, tt.want)
+			}
+			if after != before {
+				t.Fatalf("mode %d %s: invalid output sent to core", mode, tt.name)
+			}
+		}
+	}
+}
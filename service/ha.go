@@ -0,0 +1,93 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ytuox/elink-sdk-go/interfaces"
+	"github.com/ytuox/elink-sdk-go/internal/election"
+	"github.com/ytuox/elink-sdk-go/model"
+	"github.com/ytuox/elink-sdk-go/pkg/storage"
+)
+
+func (d *PluginService) enableHA(policy model.HAPolicy) error {
+	if d.elector != nil {
+		return errors.New("ha already enabled")
+	}
+	if d.plugin != nil {
+		return errors.New("ha must be enabled before start")
+	}
+
+	var (
+		lease election.Lease
+		err   error
+	)
+	switch policy.Backend {
+	case model.HABackendStorage, "":
+		// 租约需要读取其他实例的写入,不经过本地缓存
+		lease = election.NewStorageLease(rawStorage{d})
+	case model.HABackendFile:
+		if lease, err = election.NewFileLease(policy.LockFile); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported ha backend: %s", policy.Backend)
+	}
+
+	holder := policy.InstanceId
+	if holder == "" {
+		host, _ := os.Hostname()
+		holder = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	d.elector = election.NewElector(lease, holder, policy.LeaseTime, policy.RenewInterval, d.logger, d.leadershipChanged)
+	return nil
+}
+
+// isLeader 未启用主备模式时始终为主实例
+func (d *PluginService) isLeader() bool {
+	return d.elector == nil || d.elector.IsLeader()
+}
+
+func (d *PluginService) leadershipChanged(leader bool) {
+	if p, ok := d.plugin.(interfaces.LeaderAware); ok {
+		p.LeadershipChanged(d.ctx, leader)
+	}
+}
+
+// rawStorage 直接访问核心服务的自定义存储
+type rawStorage struct {
+	d *PluginService
+}
+
+var _ storage.Backend = rawStorage{}
+
+func (r rawStorage) GetCustomStorage(keys []string) (map[string][]byte, error) {
+	return r.d.fetchStorage(keys)
+}
+
+func (r rawStorage) PutCustomStorage(kvs map[string][]byte) error {
+	return r.d.storeStorage(kvs)
+}
+
+func (r rawStorage) DeleteCustomStorage(keys []string) error {
+	return r.d.removeStorage(keys)
+}
+
+func (r rawStorage) GetAllCustomStorage() (map[string][]byte, error) {
+	return r.d.fetchAllStorage()
+}
@@ -0,0 +1,67 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/interfaces"
+	"github.com/ytuox/elink-sdk-go/internal/logger"
+	"github.com/ytuox/elink-sdk-go/model"
+)
+
+// LoggingMiddleware 记录下行消息及处理结果
+func LoggingMiddleware(l logger.Logger) interfaces.Middleware {
+	return func(next interfaces.DownlinkHandler) interfaces.DownlinkHandler {
+		return func(ctx context.Context, msg *model.DownlinkMessage) error {
+			l.Infof("downlink %s msgId: %s device: %s", msg.Type, msg.MsgId(), msg.Device.Id)
+			err := next(ctx, msg)
+			if err != nil {
+				l.Errorf("downlink %s msgId: %s device: %s error: %s", msg.Type, msg.MsgId(), msg.Device.Id, err)
+			}
+			return err
+		}
+	}
+}
+
+// TimingMiddleware 统计下行消息的处理耗时
+func TimingMiddleware(observe func(msg *model.DownlinkMessage, elapsed time.Duration, err error)) interfaces.Middleware {
+	return func(next interfaces.DownlinkHandler) interfaces.DownlinkHandler {
+		return func(ctx context.Context, msg *model.DownlinkMessage) error {
+			start := time.Now()
+			err := next(ctx, msg)
+			observe(msg, time.Since(start), err)
+			return err
+		}
+	}
+}
+
+// RecoveryMiddleware 将插件处理下行消息时的panic转换为错误
+func RecoveryMiddleware(l logger.Logger) interfaces.Middleware {
+	return func(next interfaces.DownlinkHandler) interfaces.DownlinkHandler {
+		return func(ctx context.Context, msg *model.DownlinkMessage) (err error) {
+			defer func() {
+				if e := recover(); e != nil {
+					l.Errorf("%s", debug.Stack())
+					err = fmt.Errorf("panic:%v", e)
+				}
+			}()
+			return next(ctx, msg)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/interfaces"
+	"github.com/ytuox/elink-sdk-go/internal/logger"
+	"github.com/ytuox/elink-sdk-go/model"
+
+	pb_thingmodel "github.com/ytuox/elink-plugin-proto/thingmodel"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMiddlewareWrapsPlugin(t *testing.T) {
+	d, _ := newTestService(t)
+	plugin := withPlugin(t, d)
+
+	var msgs []*model.DownlinkMessage
+	d.Use(func(next interfaces.DownlinkHandler) interfaces.DownlinkHandler {
+		return func(ctx context.Context, msg *model.DownlinkMessage) error {
+			msgs = append(msgs, msg)
+			// 中间件可以改写下行数据
+			if msg.PropertySet != nil {
+				msg.PropertySet.Data["humidity"] = 40
+			}
+			return next(ctx, msg)
+		}
+	})
+
+	set := model.PropertySet{CommonRequest: model.CommonRequest{MsgId: "m1"}, Data: map[string]interface{}{"temp": 20}}
+	if err := down(d, "d1", pb_thingmodel.OperationType_PROPERTY_SET, set); err != nil {
+		t.Fatal(err)
+	}
+	if err := execute(d, "m2", "reboot", map[string]interface{}{"delay": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].Type != model.DownlinkPropertySet || msgs[1].Type != model.DownlinkServiceExecute {
+		t.Fatalf("middleware saw %v, want property set then service execute", msgs)
+	}
+	if msgs[0].Device.Id != "d1" || msgs[0].Product.Id != "p1" || msgs[0].MsgId() != "m1" || msgs[1].ServiceExecute.Spec.Identifier != "reboot" {
+		t.Fatalf("downlink message = %+v, want device, product and spec", msgs[0])
+	}
+
+	plugin.mu.Lock()
+	defer plugin.mu.Unlock()
+	if len(plugin.sets) != 1 || plugin.sets[0].Data["humidity"] != 40 {
+		t.Fatalf("plugin property sets = %+v, want data rewritten by middleware", plugin.sets)
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	d, _ := newTestService(t)
+	plugin := withPlugin(t, d)
+
+	var observed []error
+	d.Use(
+		TimingMiddleware(func(_ *model.DownlinkMessage, _ time.Duration, err error) {
+			observed = append(observed, err)
+		}),
+		RecoveryMiddleware(logger.NewLogger("", "error", "test")),
+		func(next interfaces.DownlinkHandler) interfaces.DownlinkHandler {
+			return func(ctx context.Context, msg *model.DownlinkMessage) error {
+				switch msg.MsgId() {
+				case "deny":
+					return errors.New("not authorized")
+				case "panic":
+					panic("broken middleware")
+				}
+				return next(ctx, msg)
+			}
+		},
+	)
+
+	tests := []struct {
+		msgId   string
+		wantErr string
+		called  int
+	}{
+		{"deny", "not authorized", 0},
+		{"panic", "panic:broken middleware", 0},
+		{"pass", "", 1},
+	}
+	for i, tt := range tests {
+		set := model.PropertySet{CommonRequest: model.CommonRequest{MsgId: tt.msgId}, Data: map[string]interface{}{"temp": 20}}
+		err := down(d, "d1", pb_thingmodel.OperationType_PROPERTY_SET, set)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Fatalf("%s: error = %v", tt.msgId, err)
+			}
+		} else if status.Code(err) != codes.Unknown || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Fatalf("%s: error = %v, want %q from the middleware", tt.msgId, err, tt.wantErr)
+		}
+		if sets, _, _ := plugin.calls(); sets != tt.called {
+			t.Fatalf("%s: plugin got %d property sets, want %d", tt.msgId, sets, tt.called)
+		}
+		// 外层的耗时统计中间件看到内层返回的错误
+		if len(observed) != i+1 || (observed[i] == nil) != (tt.wantErr == "") {
+			t.Fatalf("%s: timing middleware observed %v", tt.msgId, observed)
+		}
+	}
+}
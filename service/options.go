@@ -0,0 +1,168 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/internal/cache"
+	"github.com/ytuox/elink-sdk-go/internal/config"
+	"github.com/ytuox/elink-sdk-go/internal/logger"
+	"github.com/ytuox/elink-sdk-go/internal/snowflake"
+	"github.com/ytuox/elink-sdk-go/model"
+
+	"google.golang.org/grpc"
+)
+
+// Option NewPluginService 的可选参数
+type Option func(*options)
+
+type options struct {
+	logger        logger.Logger
+	clock         func() time.Time
+	dialOptions   []grpc.DialOption
+	serverOptions []grpc.ServerOption
+	workerId      int64
+	timeouts      model.Timeouts
+	deviceCache   cache.DeviceProvider
+	productCache  cache.ProductProvider
+	configFile    string
+	args          []string
+}
+
+func defaultOptions() options {
+	return options{
+		clock:    time.Now,
+		workerId: 1,
+		timeouts: model.DefaultTimeouts(),
+	}
+}
+
+// WithLogger 使用自定义日志,不再根据配置创建日志
+func WithLogger(l logger.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithClock 使用自定义时钟生成SDK产生的消息的时间戳,并计算变化上报过滤、聚合、告警、
+// 下行消息去重的时间窗口与设备孪生的时效。确认超时、调用超时、限流与命令队列等待使用系统定时器,
+// 插件直接调用 model.New* 构造的消息仍使用系统时间
+func WithClock(clock func() time.Time) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithDialOptions 追加连接核心服务的gRPC参数
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) {
+		o.dialOptions = append(o.dialOptions, opts...)
+	}
+}
+
+// WithServerOptions 追加插件gRPC服务的参数,追加拦截器时使用 grpc.ChainUnaryInterceptor
+func WithServerOptions(opts ...grpc.ServerOption) Option {
+	return func(o *options) {
+		o.serverOptions = append(o.serverOptions, opts...)
+	}
+}
+
+// WithWorkerID 设置生成消息id的雪花算法节点id,同一产品的多个插件实例应使用不同的id
+func WithWorkerID(id int64) Option {
+	return func(o *options) {
+		o.workerId = id
+	}
+}
+
+// WithTimeouts 设置调用核心服务的超时时间,为0的字段使用默认值
+func WithTimeouts(timeouts model.Timeouts) Option {
+	return func(o *options) {
+		def := model.DefaultTimeouts()
+		if timeouts.Dial == 0 {
+			timeouts.Dial = def.Dial
+		}
+		if timeouts.RPC == 0 {
+			timeouts.RPC = def.RPC
+		}
+		if timeouts.Sync == 0 {
+			timeouts.Sync = def.Sync
+		}
+		o.timeouts = timeouts
+	}
+}
+
+// WithCacheProviders 使用自定义的设备与产品缓存,不再从核心服务同步设备与产品列表
+func WithCacheProviders(dc cache.DeviceProvider, pc cache.ProductProvider) Option {
+	return func(o *options) {
+		o.deviceCache = dc
+		o.productCache = pc
+	}
+}
+
+// WithConfigFile 从yaml、json或toml格式的配置文件读取配置,覆盖 conf 参数中的同名配置
+func WithConfigFile(path string) Option {
+	return func(o *options) {
+		o.configFile = path
+	}
+}
+
+// WithFlags 从命令行参数读取配置,优先级最高,如 -adapter-rpc-address、-config。
+// 环境变量(如 ELINK_ADAPTER_RPC_ADDRESS)的优先级在配置文件与命令行参数之间
+func WithFlags(args []string) Option {
+	return func(o *options) {
+		o.args = args
+	}
+}
+
+func (o options) source(conf string) config.Source {
+	return config.Source{
+		JSON: conf,
+		File: o.configFile,
+		Args: o.args,
+	}
+}
+
+// loadConfig 合并各来源的配置,配置与可选参数的错误一并返回
+func (o options) loadConfig(conf string) (config.AdapterCfg, error) {
+	cfg, err := config.Load(o.source(conf))
+	if verr := o.validate(); verr != nil {
+		err = errors.Join(err, verr)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("invalid plugin config: %w", err)
+	}
+	return cfg, nil
+}
+
+// validate 检查可选参数
+func (o options) validate() error {
+	var errs []error
+	if o.workerId < 0 || o.workerId > snowflake.MaxWorkerId {
+		errs = append(errs, fmt.Errorf("worker id %d out of range [0, %d]", o.workerId, snowflake.MaxWorkerId))
+	}
+	if o.timeouts.Dial < 0 || o.timeouts.RPC < 0 || o.timeouts.Sync < 0 {
+		errs = append(errs, errors.New("timeouts must not be negative"))
+	}
+	if o.clock == nil {
+		errs = append(errs, errors.New("clock must not be nil"))
+	}
+	if (o.deviceCache == nil) != (o.productCache == nil) {
+		errs = append(errs, errors.New("device and product cache providers must be set together"))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,114 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/internal/cache"
+	"github.com/ytuox/elink-sdk-go/internal/snowflake"
+	"github.com/ytuox/elink-sdk-go/model"
+
+	pb_thingmodel "github.com/ytuox/elink-plugin-proto/thingmodel"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     Option
+		wantErr string
+	}{
+		{"defaults", func(*options) {}, ""},
+		{"negative worker id", WithWorkerID(-1), "worker id -1 out of range"},
+		{"worker id too large", WithWorkerID(snowflake.MaxWorkerId + 1), "out of range"},
+		{"negative timeout", WithTimeouts(model.Timeouts{RPC: -time.Second}), "timeouts must not be negative"},
+		{"nil clock", WithClock(nil), "clock must not be nil"},
+		{"device cache only", WithCacheProviders(cache.NewDeviceCache(nil), nil), "must be set together"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := defaultOptions()
+			tt.opt(&o)
+			err := o.validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithTimeoutsKeepsDefaults(t *testing.T) {
+	o := defaultOptions()
+	WithTimeouts(model.Timeouts{RPC: 2 * time.Second})(&o)
+	def := model.DefaultTimeouts()
+	if o.timeouts.RPC != 2*time.Second || o.timeouts.Dial != def.Dial || o.timeouts.Sync != def.Sync {
+		t.Fatalf("timeouts = %+v, want RPC 2s and defaults for the rest", o.timeouts)
+	}
+}
+
+func TestNewPluginServiceReportsAllErrors(t *testing.T) {
+	_, err := NewPluginService(context.Background(), `{"pluginRPC":{"address":"127.0.0.1:0"}}`, 0, WithWorkerID(-1), WithClock(nil))
+	if err == nil {
+		t.Fatal("NewPluginService() error = nil")
+	}
+	for _, want := range []string{"adapterId is required", "worker id -1", "clock must not be nil"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("NewPluginService() error missing %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	d, core := newTestService(t, WithClock(func() time.Time { return now }))
+
+	err := d.AddAlarmRule(model.AlarmRule{
+		Id:         "r1",
+		ProductId:  "p1",
+		Identifier: "temp",
+		Operator:   model.AlarmGreater,
+		Threshold:  80,
+		RaiseEvent: "high",
+		ValueParam: "value",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = d.PropertyReport("d1", report(90)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := d.GetDeviceTwin("d1")["temp"].Timestamp; got != now.UnixMilli() {
+		t.Fatalf("twin timestamp = %d, want %d", got, now.UnixMilli())
+	}
+	var ev model.EventReport
+	waitFor(t, func() bool { return len(core.uplinks(pb_thingmodel.OperationType_EVENT_REPORT)) == 1 })
+	decodeUp(t, core.uplinks(pb_thingmodel.OperationType_EVENT_REPORT)[0], &ev)
+	if ev.Data.Timestamp != now.UnixMilli() {
+		t.Fatalf("alarm event timestamp = %d, want %d", ev.Data.Timestamp, now.UnixMilli())
+	}
+	if alarms := d.GetActiveAlarms("d1"); len(alarms) != 1 || alarms[0].Timestamp != now.UnixMilli() {
+		t.Fatalf("GetActiveAlarms() = %+v, want alarm raised at the injected time", alarms)
+	}
+}
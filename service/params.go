@@ -0,0 +1,117 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/interfaces"
+	"github.com/ytuox/elink-sdk-go/internal/config"
+	"github.com/ytuox/elink-sdk-go/internal/params"
+)
+
+const paramsWatchInterval = 5 * time.Second
+
+// decodeParams 解码插件参数,并记录参数类型用于变化通知
+func (d *PluginService) decodeParams(v interface{}) error {
+	d.paramsMu.Lock()
+	defer d.paramsMu.Unlock()
+
+	if err := params.Decode(d.cfg.PluginParam, v); err != nil {
+		return err
+	}
+	d.paramsType = reflect.TypeOf(v).Elem()
+	return nil
+}
+
+func (d *PluginService) pluginParam() string {
+	d.paramsMu.Lock()
+	defer d.paramsMu.Unlock()
+	return d.cfg.PluginParam
+}
+
+// reloadParams 更新插件参数,参数变化时通知实现了 interfaces.ParamsAware 的插件。
+// 调用过 Params 时按其类型解码,校验失败时保留原参数
+func (d *PluginService) reloadParams(raw string) error {
+	d.paramsMu.Lock()
+	if raw == d.cfg.PluginParam {
+		d.paramsMu.Unlock()
+		return nil
+	}
+	var oldValue, newValue interface{} = d.cfg.PluginParam, raw
+	if d.paramsType != nil {
+		oldValue, newValue = reflect.New(d.paramsType).Interface(), reflect.New(d.paramsType).Interface()
+		// 原参数已通过校验,解码失败时忽略
+		_ = params.Decode(d.cfg.PluginParam, oldValue)
+		if err := params.Decode(raw, newValue); err != nil {
+			d.paramsMu.Unlock()
+			return err
+		}
+		if reflect.DeepEqual(oldValue, newValue) {
+			d.cfg.PluginParam = raw
+			d.paramsMu.Unlock()
+			return nil
+		}
+	}
+	d.cfg.PluginParam = raw
+	d.paramsMu.Unlock()
+
+	d.logger.Infof("plugin params changed")
+	if p, ok := d.plugin.(interfaces.ParamsAware); ok {
+		p.ParamsChanged(d.ctx, oldValue, newValue)
+	}
+	return nil
+}
+
+// watchConfigFile 配置文件修改后重新读取插件参数,其余配置需要重启生效
+func (d *PluginService) watchConfigFile(src config.Source) {
+	file := src.ConfigFile()
+	if file == "" {
+		return
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		d.logger.Errorf("watch config file %s error: %s", file, err)
+		return
+	}
+	modTime := info.ModTime()
+
+	go func() {
+		ticker := time.NewTicker(paramsWatchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			info, err := os.Stat(file)
+			if err != nil || info.ModTime().Equal(modTime) {
+				continue
+			}
+			modTime = info.ModTime()
+			cfg, err := config.Load(src)
+			if err != nil {
+				d.logger.Errorf("reload config file %s error: %s", file, err)
+				continue
+			}
+			if err = d.reloadParams(cfg.PluginParam); err != nil {
+				d.logger.Errorf("reload plugin params error: %s", err)
+			}
+		}
+	}()
+}
@@ -16,26 +16,44 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
 
 	"time"
 
 	"github.com/ytuox/elink-sdk-go/common"
 	"github.com/ytuox/elink-sdk-go/interfaces"
+	"github.com/ytuox/elink-sdk-go/internal/ack"
+	"github.com/ytuox/elink-sdk-go/internal/aggregate"
+	"github.com/ytuox/elink-sdk-go/internal/alarm"
 	"github.com/ytuox/elink-sdk-go/internal/cache"
 	"github.com/ytuox/elink-sdk-go/internal/client"
 	"github.com/ytuox/elink-sdk-go/internal/config"
+	"github.com/ytuox/elink-sdk-go/internal/dedup"
+	"github.com/ytuox/elink-sdk-go/internal/dispatcher"
+	"github.com/ytuox/elink-sdk-go/internal/election"
+	"github.com/ytuox/elink-sdk-go/internal/filter"
 	"github.com/ytuox/elink-sdk-go/internal/logger"
+	"github.com/ytuox/elink-sdk-go/internal/middleware"
 	"github.com/ytuox/elink-sdk-go/internal/server"
 	"github.com/ytuox/elink-sdk-go/internal/snowflake"
+	"github.com/ytuox/elink-sdk-go/internal/uplink"
+	"github.com/ytuox/elink-sdk-go/internal/validator"
 	"github.com/ytuox/elink-sdk-go/model"
+	"github.com/ytuox/elink-sdk-go/pkg/storage"
 	"github.com/ytuox/elink-sdk-go/util"
 
+	"github.com/spf13/cast"
 	pb_app "github.com/ytuox/elink-plugin-proto/app"
 	pb_common "github.com/ytuox/elink-plugin-proto/common"
 	pb_device "github.com/ytuox/elink-plugin-proto/device"
 	pb_storage "github.com/ytuox/elink-plugin-proto/storage"
 	pb_thingmodel "github.com/ytuox/elink-plugin-proto/thingmodel"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -47,41 +65,91 @@ type PluginService struct {
 	logger       logger.Logger
 	deviceCache  cache.DeviceProvider
 	productCache cache.ProductProvider
-	plugin       interfaces.Plugin
-	rpcClient    *client.ResourceClient
-	rpcServer    *server.RPCService
-	baseMessage  common.BaseMessage
-	node         *snowflake.Worker
-}
-
-func NewPluginService(ctx context.Context, conf string, direction common.DataDirection) (*PluginService, error) {
-	var cfg config.AdapterCfg
-
-	util.StringDecoder(conf, &cfg)
+	twinCache    cache.TwinProvider
+	reportFilter *filter.ReportFilter
+	aggregator   *aggregate.Aggregator
+	alarmEngine  *alarm.Engine
+	validator    *validator.Validator
+	dispatcher   *dispatcher.Dispatcher
+	dedup        *dedup.Cache
+	chain        *middleware.Chain
+	uplink       *uplink.Scheduler
+	pending      *ack.Pending
+	storageCache *cache.StorageCache
+
+	store       *storage.Store
+	secureOnce  sync.Once
+	secureStore *storage.Store
+	secureErr   error
+	elector     *election.Elector
+
+	timeouts      model.Timeouts
+	now           func() time.Time
+	serverOptions []grpc.ServerOption
+	configSource  config.Source
+
+	paramsMu    sync.Mutex
+	paramsType  reflect.Type
+	limitMu     sync.RWMutex
+	uplinkLimit model.UplinkLimit
+	plugin      interfaces.Plugin
+	rpcClient   *client.ResourceClient
+	rpcServer   *server.RPCService
+	baseMessage common.BaseMessage
+	node        *snowflake.Worker
+
+	desiredReconcile atomic.Bool
+	desiredMu        sync.Mutex
+	desiredSets      map[string]desiredSet
+}
+
+// NewPluginService 创建插件服务,conf为JSON格式的配置,使用 WithConfigFile 或 WithFlags 时可以为空
+func NewPluginService(ctx context.Context, conf string, direction common.DataDirection, opts ...Option) (*PluginService, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	cfg, err := o.loadConfig(conf)
+	if err != nil {
+		return nil, err
+	}
 
-	log := logger.NewLogger(cfg.Logger.Path, cfg.Logger.Level, cfg.AdapterId)
+	log := o.logger
+	if log == nil {
+		log = logger.NewLogger(cfg.Logger.Path, cfg.Logger.Level, cfg.AdapterId)
+	}
 
 	// Start rpc client
-	coreClient, err := client.NewCoreClient(cfg.AdapterRPC)
+	coreClient, err := client.NewCoreClient(cfg.AdapterRPC, log, o.timeouts.Dial, o.dialOptions...)
 	if err != nil {
 		log.Errorf("new resource client error: %s", err)
-		return nil, err
+		return nil, fmt.Errorf("connect core service(%s) error: %w", cfg.AdapterRPC.Address, err)
 	}
 
 	// Snowflake node
-	node, err := snowflake.NewWorker(1)
+	node, err := snowflake.NewWorker(o.workerId)
 	if err != nil {
 		log.Errorf("new msg id generator error: %s", err)
 		return nil, err
 	}
 
+	twin := cache.NewTwinCache()
+	twin.SetClock(o.clock)
 	pluginService := &PluginService{
-		ctx:       ctx,
-		rpcClient: coreClient,
-		logger:    log,
-		cfg:       cfg,
-		node:      node,
-		direction: direction,
+		ctx:           ctx,
+		rpcClient:     coreClient,
+		logger:        log,
+		cfg:           cfg,
+		node:          node,
+		direction:     direction,
+		twinCache:     twin,
+		uplinkLimit:   model.DefaultUplinkLimit(),
+		timeouts:      o.timeouts,
+		now:           o.clock,
+		serverOptions: o.serverOptions,
+		configSource:  o.source(conf),
+		deviceCache:   o.deviceCache,
+		productCache:  o.productCache,
 	}
 
 	if err = pluginService.buildRpcBaseMessage(); err != nil {
@@ -93,6 +161,22 @@ func NewPluginService(ctx context.Context, conf string, direction common.DataDir
 		log.Error("initCache error:", err)
 		return nil, err
 	}
+	pluginService.reportFilter = filter.NewReportFilter(pluginService.productCache)
+	pluginService.aggregator = aggregate.NewAggregator(ctx, pluginService.emitAggregate)
+	pluginService.alarmEngine = alarm.NewEngine(pluginService.productCache)
+	pluginService.validator = validator.NewValidator(pluginService.productCache)
+	pluginService.dispatcher = dispatcher.NewDispatcher()
+	pluginService.dedup = dedup.NewCache()
+	pluginService.chain = middleware.NewChain()
+	pluginService.uplink = uplink.NewScheduler(ctx)
+	pluginService.pending = ack.NewPending()
+	// 各组件使用同一时钟计算时间窗口与生成时间戳
+	pluginService.reportFilter.SetClock(o.clock)
+	pluginService.aggregator.SetClock(o.clock)
+	pluginService.alarmEngine.SetClock(o.clock)
+	pluginService.dedup.SetClock(o.clock)
+	// GetRevision 与 CompareAndSet 绕过自定义存储的本地缓存
+	pluginService.store = storage.New(pluginService).WithDirect(rawStorage{pluginService})
 
 	return pluginService, nil
 }
@@ -106,8 +190,11 @@ func (d *PluginService) buildRpcBaseMessage() error {
 }
 
 func (d *PluginService) initCache() error {
+	if d.deviceCache != nil && d.productCache != nil {
+		return nil
+	}
 	// Sync device
-	if deviceCache, err := cache.InitDeviceCache(d.baseMessage, d.rpcClient, d.logger); err != nil {
+	if deviceCache, err := cache.InitDeviceCache(d.baseMessage, d.rpcClient, d.logger, d.timeouts.Sync); err != nil {
 		d.logger.Errorf("sync device error: %s", err.Error())
 		return err
 	} else {
@@ -115,7 +202,7 @@ func (d *PluginService) initCache() error {
 	}
 
 	// Sync product
-	if productCache, err := cache.InitProductCache(d.baseMessage, d.rpcClient, d.logger); err != nil {
+	if productCache, err := cache.InitProductCache(d.baseMessage, d.rpcClient, d.logger, d.timeouts.Sync); err != nil {
 		d.logger.Errorf("sync tsl error: %s", err.Error())
 		return err
 	} else {
@@ -125,162 +212,216 @@ func (d *PluginService) initCache() error {
 }
 
 func (d *PluginService) start(plugin interfaces.Plugin) error {
+	if err := d.newRPCServer(plugin); err != nil {
+		return err
+	}
+
+	// rpcServer.Start 在服务停止前不会返回,后台任务需要在此之前启动
+	if d.elector != nil {
+		d.elector.Start(d.ctx)
+	}
+	d.watchConfigFile(d.configSource)
+
+	return d.rpcServer.Start()
+}
 
+// newRPCServer 创建接收核心服务下行消息的插件服务
+func (d *PluginService) newRPCServer(plugin interfaces.Plugin) error {
 	if plugin == nil {
 		return errors.New("plugin unimplemented")
 	}
 	d.plugin = plugin
 
 	var err error
-
-	// rpc server
-	d.rpcServer, err = server.NewRPCService(d.ctx, d.cfg.PluginRPC, d.deviceCache, d.productCache, d.plugin, d.rpcClient, d.logger)
-	if err != nil {
-		return err
-	}
-
-	err = d.rpcServer.Start()
-	if err != nil {
-		return err
-	}
-
-	return nil
+	d.rpcServer, err = server.NewRPCService(d.ctx, d.cfg.PluginRPC, d.deviceCache, d.productCache, d.plugin, d.rpcClient, server.Extensions{
+		Responder:  d,
+		Twin:       d.twinCache,
+		Validator:  d.validator,
+		Dispatcher: d.dispatcher,
+		Dedup:      d.dedup,
+		Chain:      d.chain,
+		Acker:      d.pending,
+		Leader:     d,
+		Removers:   []server.DeviceRemover{d.twinCache, d.reportFilter, d.aggregator, d.alarmEngine, d.dispatcher, d.dedup},
+		Clock:      d.now,
+	}, d.logger, d.serverOptions...)
+	return err
 }
 
 func (d *PluginService) stop() error {
+	if d.elector != nil {
+		d.elector.Stop()
+	}
 	return d.rpcServer.Stop()
 }
 
-func (d *PluginService) propertySetResponse(cid string, data model.PropertySetResponse) error {
-	msg, err := common.TransformToProtoMsg(cid, common.PropertySetResponse, data, d.baseMessage)
-	if err != nil {
-		return err
+// thingModelMsgUp 发送物模型上行消息,启用限流时按优先级排队发送,备实例不发送
+func (d *PluginService) thingModelMsgUp(cid string, t int, data interface{}) (*pb_common.CommonResponse, error) {
+	if !d.IsLeader() {
+		return nil, election.ErrStandby
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
-
-	if _, err = d.rpcClient.ThingModelMsgUp(ctx, msg); err != nil {
-		return errors.New(status.Convert(err).Message())
-	}
-	return nil
-}
-
-func (d *PluginService) propertyGetResponse(cid string, data model.PropertyGetResponse) error {
-	msg, err := common.TransformToProtoMsg(cid, common.PropertyGetResponse, data, d.baseMessage)
+	msg, err := common.TransformToProtoMsg(cid, t, data, d.baseMessage)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
 
-	if _, err = d.rpcClient.ThingModelMsgUp(ctx, msg); err != nil {
-		return errors.New(status.Convert(err).Message())
+	resp, err := d.uplink.Submit(cid, uplinkPriority(t), func() (*pb_common.CommonResponse, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), d.timeouts.RPC)
+		defer cancel()
+		return d.rpcClient.ThingModelMsgUp(ctx, msg)
+	})
+	if err != nil {
+		return nil, errors.New(status.Convert(err).Message())
 	}
-	return nil
+	return resp, nil
 }
 
-func (d *PluginService) serviceExecuteResponse(cid string, data model.ServiceExecuteResponse) error {
-	msg, err := common.TransformToProtoMsg(cid, common.ServiceExecuteResponse, data, d.baseMessage)
-	if err != nil {
-		return err
+// uplinkPriority 事件与下行命令的应答优先于属性上报发送
+func uplinkPriority(t int) uplink.Priority {
+	switch t {
+	case common.EventReport, common.PropertySetResponse, common.PropertyGetResponse, common.ServiceExecuteResponse:
+		return uplink.PriorityHigh
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
+	return uplink.PriorityLow
+}
 
-	if _, err = d.rpcClient.ThingModelMsgUp(ctx, msg); err != nil {
-		return errors.New(status.Convert(err).Message())
+// reportUp 发送上报消息,消息要求确认时等待核心服务确认或超时
+func (d *PluginService) reportUp(cid string, t int, msgId string, sys *model.ACK, data interface{}) (model.CommonResponse, error) {
+	if !sys.Required() {
+		resp, err := d.thingModelMsgUp(cid, t, data)
+		if err != nil {
+			return model.CommonResponse{}, err
+		}
+		return model.NewCommonResponse(resp), nil
 	}
-	return nil
-}
 
-func (d *PluginService) propertyReport(cid string, data model.PropertyReport) (model.CommonResponse, error) {
-	msg, err := common.TransformToProtoMsg(cid, common.PropertyReport, data, d.baseMessage)
+	mac := d.pending.Add(cid, msgId)
+	resp, err := d.thingModelMsgUp(cid, t, data)
 	if err != nil {
+		d.pending.Cancel(mac)
 		return model.CommonResponse{}, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
-
-	thingModelResp := new(pb_common.CommonResponse)
-	if thingModelResp, err = d.rpcClient.ThingModelMsgUp(ctx, msg); err != nil {
-		return model.CommonResponse{}, errors.New(status.Convert(err).Message())
+	if !resp.GetSuccess() {
+		d.pending.Cancel(mac)
+		return model.NewCommonResponse(resp), nil
 	}
-
-	return model.NewCommonResponse(thingModelResp), nil
+	payload, err := d.pending.Wait(mac)
+	if err != nil {
+		return model.CommonResponse{}, fmt.Errorf("message(%s): %w", msgId, err)
+	}
+	return decodeAck(payload)
 }
 
-func (d *PluginService) eventReport(cid string, data model.EventReport) (model.CommonResponse, error) {
-	msgId := d.node.GetId().String()
-	data.MsgId = msgId
-	msg, err := common.TransformToProtoMsg(cid, common.EventReport, data, d.baseMessage)
-	if err != nil {
+// decodeAck 解析核心服务的确认消息,未携带结果时视为成功
+func decodeAck(payload string) (model.CommonResponse, error) {
+	var ack struct {
+		Code         interface{} `json:"code"`
+		Success      *bool       `json:"success"`
+		ErrorMessage string      `json:"errorMessage"`
+	}
+	if err := json.Unmarshal([]byte(payload), &ack); err != nil {
 		return model.CommonResponse{}, err
 	}
+	return model.CommonResponse{
+		ErrorMessage: ack.ErrorMessage,
+		Code:         cast.ToString(ack.Code),
+		Success:      ack.Success == nil || *ack.Success,
+	}, nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
-
-	thingModelResp := new(pb_common.CommonResponse)
-	if thingModelResp, err = d.rpcClient.ThingModelMsgUp(ctx, msg); err != nil {
-		return model.CommonResponse{}, errors.New(status.Convert(err).Message())
+// fillMsgId 未指定MsgId的上行消息使用雪花算法生成
+func (d *PluginService) fillMsgId(msgId *string) {
+	if *msgId == "" {
+		*msgId = d.node.GetId().String()
 	}
-
-	return model.NewCommonResponse(thingModelResp), nil
 }
 
-func (d *PluginService) batchReport(cid string, data model.BatchReport) (model.CommonResponse, error) {
-	msgId := d.node.GetId().String()
-	data.MsgId = msgId
-	msg, err := common.TransformToProtoMsg(cid, common.BatchReport, data, d.baseMessage)
-	if err != nil {
-		return model.CommonResponse{}, err
+func (d *PluginService) propertySetResponse(cid string, data model.PropertySetResponse) error {
+	d.fillMsgId(&data.MsgId)
+	if _, err := d.thingModelMsgUp(cid, common.PropertySetResponse, data); err != nil {
+		return err
 	}
+	d.dedup.Record(cid, data.MsgId, data)
+	d.desiredResponse(cid, data)
+	return nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
+func (d *PluginService) propertyGetResponse(cid string, data model.PropertyGetResponse) error {
+	d.fillMsgId(&data.MsgId)
+	_, err := d.thingModelMsgUp(cid, common.PropertyGetResponse, data)
+	return err
+}
 
-	thingModelResp := new(pb_common.CommonResponse)
-	if thingModelResp, err = d.rpcClient.ThingModelMsgUp(ctx, msg); err != nil {
-		return model.CommonResponse{}, errors.New(status.Convert(err).Message())
+func (d *PluginService) serviceExecuteResponse(cid string, data model.ServiceExecuteResponse) error {
+	if err := d.checkServiceOutput(cid, data.Data); err != nil {
+		return err
 	}
-
-	return model.NewCommonResponse(thingModelResp), nil
+	d.fillMsgId(&data.MsgId)
+	if _, err := d.thingModelMsgUp(cid, common.ServiceExecuteResponse, data); err != nil {
+		return err
+	}
+	d.dedup.Record(cid, data.MsgId, data)
+	return nil
 }
 
-func (d *PluginService) propertyDesiredGet(deviceId string, data model.PropertyDesiredGet) (model.PropertyDesiredGetResponse, error) {
-	msgId := d.node.GetId().String()
-	data.MsgId = msgId
-	msg, err := common.TransformToProtoMsg(deviceId, common.PropertyDesiredGet, data, d.baseMessage)
-	if err != nil {
-		return model.PropertyDesiredGetResponse{}, err
+// checkServiceOutput 校验服务调用的输出参数,严格校验模式下返回错误
+func (d *PluginService) checkServiceOutput(deviceId string, data model.ServiceDataOut) error {
+	mode := d.validator.ServiceMode()
+	if mode == model.ValidateOff {
+		return nil
+	}
+	device, ok := d.deviceCache.SearchById(deviceId)
+	if !ok {
+		return nil
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
 
-	thingModelResp := new(pb_common.CommonResponse)
-	if thingModelResp, err = d.rpcClient.ThingModelMsgUp(ctx, msg); err != nil {
-		return model.PropertyDesiredGetResponse{}, errors.New(status.Convert(err).Message())
+	var msg string
+	if spec, ok := d.productCache.GetServiceSpecByIdentifier(device.ProductId, data.ServiceId); !ok {
+		msg = "unknown service"
+	} else if reasons := validator.CheckParams(spec.Output, data.Output); len(reasons) > 0 {
+		msg = validator.Message(reasons)
+	} else {
+		return nil
+	}
+	if mode == model.ValidateLenient {
+		d.logger.Warnf("invalid service(%s) output of device(%s): %s", data.ServiceId, deviceId, msg)
+		return nil
 	}
-	d.logger.Info(thingModelResp)
-	return model.PropertyDesiredGetResponse{}, nil
+	return fmt.Errorf("invalid service(%s) output: %s", data.ServiceId, msg)
 }
 
-func (d *PluginService) propertyDesiredDelete(deviceId string, data model.PropertyDesiredDelete) (model.CommonResponse, error) {
-	msgId := d.node.GetId().String()
-	data.MsgId = msgId
-	msg, err := common.TransformToProtoMsg(deviceId, common.PropertyDesiredDelete, data, d.baseMessage)
-	if err != nil {
-		return model.CommonResponse{}, err
+func (d *PluginService) propertyReport(cid string, data model.PropertyReport) (model.CommonResponse, error) {
+	d.twinCache.Update(cid, data.Timestamp, data.Data)
+
+	var (
+		productId string
+		full      bool
+	)
+	if device, ok := d.deviceCache.SearchById(cid); ok {
+		productId = device.ProductId
+		if transitions := d.alarmEngine.Evaluate(productId, cid, data.Data); len(transitions) > 0 {
+			defer d.reportAlarms(transitions)
+		}
+		if data.Data, full = d.reportFilter.Filter(productId, cid, data.Data); len(data.Data) == 0 {
+			return model.CommonResponse{Success: true}, nil
+		}
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
 
-	thingModelResp := new(pb_common.CommonResponse)
-	if thingModelResp, err = d.rpcClient.ThingModelMsgUp(ctx, msg); err != nil {
-		return model.CommonResponse{}, errors.New(status.Convert(err).Message())
+	resp, err := d.sendPropertyReport(cid, data)
+	if err == nil && resp.Success {
+		d.reportFilter.Commit(productId, cid, data.Data, full)
 	}
+	return resp, err
+}
 
-	return model.NewCommonResponse(thingModelResp), nil
+func (d *PluginService) sendPropertyReport(cid string, data model.PropertyReport) (model.CommonResponse, error) {
+	d.fillMsgId(&data.MsgId)
+	return d.reportUp(cid, common.PropertyReport, data.MsgId, data.Sys, data)
+}
+
+func (d *PluginService) eventReport(cid string, data model.EventReport) (model.CommonResponse, error) {
+	d.fillMsgId(&data.MsgId)
+	return d.reportUp(cid, common.EventReport, data.MsgId, data.Sys, data)
 }
 
 func (d *PluginService) connectIotPlatform(deviceId string) error {
@@ -292,7 +433,7 @@ func (d *PluginService) connectIotPlatform(deviceId string) error {
 		return errors.New("required device id")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeouts.RPC)
 	defer cancel()
 	req := pb_device.ConnectIotPlatformRequest{
 		BaseRequest: d.baseMessage.BuildBaseRequest(),
@@ -306,6 +447,9 @@ func (d *PluginService) connectIotPlatform(deviceId string) error {
 			return errors.New(resp.BaseResponse.Message)
 		}
 		if resp.Data.Status == pb_device.ConnectStatus_ONLINE {
+			if d.desiredReconcile.Load() {
+				go d.reconcileOnline(deviceId)
+			}
 			return nil
 		} else if resp.Data.Status == pb_device.ConnectStatus_OFFLINE {
 
@@ -320,7 +464,7 @@ func (d *PluginService) disconnectIotPlatform(deviceId string) error {
 		resp *pb_device.DisconnectIotPlatformResponse
 	)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeouts.RPC)
 	defer cancel()
 	req := pb_device.DisconnectIotPlatformRequest{
 		BaseRequest: d.baseMessage.BuildBaseRequest(),
@@ -352,7 +496,7 @@ func (d *PluginService) getConnectStatus(deviceId string) (common.DeviceConnectS
 		return "", errors.New("required device cid")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeouts.RPC)
 	defer cancel()
 	req := pb_device.GetDeviceConnectStatusRequest{
 		BaseRequest: d.baseMessage.BuildBaseRequest(),
@@ -395,7 +539,7 @@ func (d *PluginService) getDeviceListByAppId(id string) []model.Device {
 func (d *PluginService) getDeviceListByUserId(userId string) ([]model.Device, error) {
 	var devices []model.Device
 
-	c, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	c, cancel := context.WithTimeout(context.Background(), d.timeouts.Sync)
 	defer cancel()
 	resp, err := d.rpcClient.RPCDeviceClient.QueryDeviceListByUserId(c, &pb_device.QueryDeviceListByUserIdRequest{
 		BaseRequest: d.baseMessage.BuildBaseRequest(),
@@ -428,7 +572,7 @@ func (d *PluginService) getDeviceById(deviceId string) (model.Device, bool) {
 
 func (d *PluginService) getDevicePropertyShadow(deviceId, identifier string) ([]model.PropertyShadowData, error) {
 
-	c, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	c, cancel := context.WithTimeout(context.Background(), d.timeouts.RPC)
 	defer cancel()
 
 	resp, err := d.rpcClient.RPCThingModelClient.QueryThingModelShadow(c, &pb_thingmodel.QueryThingModelShadowRequest{
@@ -456,7 +600,7 @@ func (d *PluginService) getDevicePropertyShadow(deviceId, identifier string) ([]
 
 func (d *PluginService) getDeviceServiceShadow(deviceId, identifier string) ([]model.ServiceShadowData, error) {
 
-	c, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	c, cancel := context.WithTimeout(context.Background(), d.timeouts.RPC)
 	defer cancel()
 
 	resp, err := d.rpcClient.RPCThingModelClient.QueryThingModelShadow(c, &pb_thingmodel.QueryThingModelShadowRequest{
@@ -488,7 +632,7 @@ func (d *PluginService) createDevice(addDevice model.AddDevice) (model.Device, e
 		return model.Device{}, errors.New("param failed")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeouts.RPC)
 	defer cancel()
 	reqDevice := new(pb_device.AddDevice)
 	reqDevice.Name = addDevice.Name
@@ -522,6 +666,22 @@ func (d *PluginService) createDevice(addDevice model.AddDevice) (model.Device, e
 	return deviceInfo, errors.New("unKnow error")
 }
 
+func (d *PluginService) setTwinPolicy(productId string, policy model.TwinPolicy) error {
+	if _, ok := d.productCache.SearchById(productId); !ok {
+		return errors.New("product not found")
+	}
+	d.twinCache.SetPolicy(productId, policy)
+	return nil
+}
+
+func (d *PluginService) setReportFilterPolicy(productId string, policy model.ReportFilterPolicy) error {
+	if _, ok := d.productCache.SearchById(productId); !ok {
+		return errors.New("product not found")
+	}
+	d.reportFilter.SetPolicy(productId, policy)
+	return nil
+}
+
 func (d *PluginService) getProductProperties(productId string) (map[string]model.Property, bool) {
 	return d.productCache.GetProductProperties(productId)
 }
@@ -546,11 +706,11 @@ func (d *PluginService) getProductServiceByIdentifier(productId, identifier stri
 	return d.productCache.GetServiceSpecByIdentifier(productId, identifier)
 }
 
-func (d *PluginService) getCustomStorage(keys []string) (map[string][]byte, error) {
+func (d *PluginService) fetchStorage(keys []string) (map[string][]byte, error) {
 	if len(keys) <= 0 {
 		return nil, errors.New("required keys")
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeouts.RPC)
 	defer cancel()
 	var req = pb_storage.GetReq{
 		PluginId: d.cfg.GetAdapterId(),
@@ -568,11 +728,11 @@ func (d *PluginService) getCustomStorage(keys []string) (map[string][]byte, erro
 	}
 }
 
-func (d *PluginService) putCustomStorage(kvs map[string][]byte) error {
+func (d *PluginService) storeStorage(kvs map[string][]byte) error {
 	if len(kvs) <= 0 {
 		return errors.New("required key value")
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeouts.RPC)
 	defer cancel()
 
 	var kv []*pb_storage.KV
@@ -594,11 +754,11 @@ func (d *PluginService) putCustomStorage(kvs map[string][]byte) error {
 
 }
 
-func (d *PluginService) deleteCustomStorage(keys []string) error {
+func (d *PluginService) removeStorage(keys []string) error {
 	if len(keys) <= 0 {
 		return errors.New("required keys")
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeouts.RPC)
 	defer cancel()
 	var req = pb_storage.DeleteReq{
 		PluginId: d.cfg.GetAdapterId(),
@@ -611,8 +771,8 @@ func (d *PluginService) deleteCustomStorage(keys []string) error {
 	return nil
 }
 
-func (d *PluginService) getAllCustomStorage() (map[string][]byte, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+func (d *PluginService) fetchAllStorage() (map[string][]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeouts.RPC)
 	defer cancel()
 	if resp, err := d.rpcClient.StorageClient.All(ctx, &pb_storage.AllReq{
 		PluginId: d.cfg.GetAdapterId(),
@@ -0,0 +1,350 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/common"
+	"github.com/ytuox/elink-sdk-go/internal/cache"
+	"github.com/ytuox/elink-sdk-go/internal/logger"
+	"github.com/ytuox/elink-sdk-go/model"
+
+	pb_common "github.com/ytuox/elink-plugin-proto/common"
+	pb_storage "github.com/ytuox/elink-plugin-proto/storage"
+	pb_thingmodel "github.com/ytuox/elink-plugin-proto/thingmodel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// fakeCore 模拟核心服务,记录上行消息并在内存中保存自定义存储
+type fakeCore struct {
+	pb_thingmodel.UnimplementedRPCThingModelServer
+	pb_storage.UnimplementedStorageServer
+
+	mu     sync.Mutex
+	ups    []*pb_thingmodel.ThingModelMsgUpRequest
+	reply  func(req *pb_thingmodel.ThingModelMsgUpRequest) *pb_common.CommonResponse
+	kvs    map[string][]byte
+	reads  int
+	shadow []model.PropertyShadowData
+}
+
+func (c *fakeCore) ThingModelMsgUp(_ context.Context, req *pb_thingmodel.ThingModelMsgUpRequest) (*pb_common.CommonResponse, error) {
+	c.mu.Lock()
+	c.ups = append(c.ups, req)
+	reply := c.reply
+	c.mu.Unlock()
+	if reply != nil {
+		return reply(req), nil
+	}
+	return &pb_common.CommonResponse{Success: true}, nil
+}
+
+func (c *fakeCore) QueryThingModelShadow(_ context.Context, req *pb_thingmodel.QueryThingModelShadowRequest) (*pb_thingmodel.QueryThingModelShadowResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, err := json.Marshal(c.shadow)
+	if err != nil {
+		return nil, err
+	}
+	return &pb_thingmodel.QueryThingModelShadowResponse{
+		BaseResponse: &pb_common.CommonResponse{Success: true},
+		DeviceId:     req.GetDeviceId(),
+		Data:         string(data),
+	}, nil
+}
+
+func (c *fakeCore) Get(_ context.Context, req *pb_storage.GetReq) (*pb_storage.KVs, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reads++
+	kvs := new(pb_storage.KVs)
+	for _, k := range req.GetKeys() {
+		if v, ok := c.kvs[k]; ok {
+			kvs.Kvs = append(kvs.Kvs, &pb_storage.KV{Key: k, Value: v})
+		}
+	}
+	return kvs, nil
+}
+
+func (c *fakeCore) All(context.Context, *pb_storage.AllReq) (*pb_storage.KVs, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reads++
+	kvs := new(pb_storage.KVs)
+	for k, v := range c.kvs {
+		kvs.Kvs = append(kvs.Kvs, &pb_storage.KV{Key: k, Value: v})
+	}
+	return kvs, nil
+}
+
+func (c *fakeCore) Put(_ context.Context, req *pb_storage.PutReq) (*emptypb.Empty, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, kv := range req.GetData() {
+		c.kvs[kv.GetKey()] = kv.GetValue()
+	}
+	return new(emptypb.Empty), nil
+}
+
+func (c *fakeCore) Delete(_ context.Context, req *pb_storage.DeleteReq) (*emptypb.Empty, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range req.GetKeys() {
+		delete(c.kvs, k)
+	}
+	return new(emptypb.Empty), nil
+}
+
+// uplinks 返回指定类型的上行消息
+func (c *fakeCore) uplinks(op pb_thingmodel.OperationType) []*pb_thingmodel.ThingModelMsgUpRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var ups []*pb_thingmodel.ThingModelMsgUpRequest
+	for _, up := range c.ups {
+		if up.GetOperationType() == op {
+			ups = append(ups, up)
+		}
+	}
+	return ups
+}
+
+func (c *fakeCore) setReply(reply func(req *pb_thingmodel.ThingModelMsgUpRequest) *pb_common.CommonResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reply = reply
+}
+
+func (c *fakeCore) setShadow(shadow []model.PropertyShadowData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shadow = shadow
+}
+
+func (c *fakeCore) storageReads() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reads
+}
+
+// decodeUp 解析上行消息的数据
+func decodeUp(t *testing.T, up *pb_thingmodel.ThingModelMsgUpRequest, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(up.GetData()), v); err != nil {
+		t.Fatalf("decode uplink %s: %s", up.GetData(), err)
+	}
+}
+
+// waitFor 等待异步处理完成
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func testProducts() []model.Product {
+	number := model.Define{Type: model.DataTypeFloat, Specs: `{"min":0,"max":100}`}
+	return []model.Product{{
+		Id: "p1",
+		Properties: []model.Property{
+			{ProductId: "p1", Identifier: "temp", Mode: "rw", Define: number},
+			{ProductId: "p1", Identifier: "humidity", Mode: "rw", Define: number},
+			{ProductId: "p1", Identifier: "serial", Mode: "r", Define: model.Define{Type: model.DataTypeText}},
+		},
+		Events: []model.Event{
+			{ProductId: "p1", Identifier: "high", Params: []model.InputOutput{{Identifier: "value", Define: number}}},
+		},
+		Services: []model.Service{
+			{
+				ProductId:  "p1",
+				Identifier: "reboot",
+				Input:      []model.InputOutput{{Identifier: "delay", Define: model.Define{Type: model.DataTypeInt, Specs: `{"min":0,"max":60}`}}},
+				Output:     []model.InputOutput{{Identifier: "ok", Define: model.Define{Type: model.DataTypeBool}}},
+			},
+		},
+	}}
+}
+
+func testDevices() []model.Device {
+	return []model.Device{
+		{Id: "d1", ProductId: "p1"},
+		{Id: "d2", ProductId: "p1"},
+	}
+}
+
+// newTestService 创建连接到 fakeCore 的插件服务
+func newTestService(t *testing.T, opts ...Option) (*PluginService, *fakeCore) {
+	t.Helper()
+	core := &fakeCore{kvs: make(map[string][]byte)}
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb_thingmodel.RegisterRPCThingModelServer(srv, core)
+	pb_storage.RegisterStorageServer(srv, core)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	conf := fmt.Sprintf(`{"adapterId":"test","adapterRPC":{"address":"bufnet"},"pluginRPC":{"address":"127.0.0.1:0"},"logger":{"path":%q,"level":"error"}}`,
+		filepath.Join(t.TempDir(), "plugin.log"))
+	opts = append([]Option{
+		WithLogger(logger.NewLogger("", "error", "test")),
+		WithCacheProviders(cache.NewDeviceCache(testDevices()), cache.NewProductCache(testProducts())),
+		WithDialOptions(grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		})),
+	}, opts...)
+	d, err := NewPluginService(ctx, conf, 0, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = d.rpcClient.Conn.Close() })
+	return d, core
+}
+
+// fakePlugin 记录插件收到的下行消息
+type fakePlugin struct {
+	mu       sync.Mutex
+	sets     []model.PropertySet
+	gets     []model.PropertyGet
+	services []model.ServiceExecuteRequest
+}
+
+func (p *fakePlugin) PluginNotify(context.Context, common.PluginNotifyType, string) error {
+	return nil
+}
+
+func (p *fakePlugin) DeviceNotify(context.Context, common.DeviceNotifyType, string, model.Device) error {
+	return nil
+}
+
+func (p *fakePlugin) ProductNotify(context.Context, common.ProductNotifyType, string, model.Product) error {
+	return nil
+}
+
+func (p *fakePlugin) Stop(context.Context) error {
+	return nil
+}
+
+func (p *fakePlugin) HandlePropertySet(_ context.Context, _ string, data model.PropertySet) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sets = append(p.sets, data)
+	return nil
+}
+
+func (p *fakePlugin) HandlePropertyGet(_ context.Context, _ string, data model.PropertyGet) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gets = append(p.gets, data)
+	return nil
+}
+
+func (p *fakePlugin) HandleServiceExecute(_ context.Context, _ string, data model.ServiceExecuteRequest) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.services = append(p.services, data)
+	return nil
+}
+
+// calls 返回属性下发、属性查询与服务调用的次数
+func (p *fakePlugin) calls() (sets, gets, services int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.sets), len(p.gets), len(p.services)
+}
+
+// withPlugin 创建处理下行消息的插件服务,不启动监听
+func withPlugin(t *testing.T, d *PluginService) *fakePlugin {
+	t.Helper()
+	plugin := new(fakePlugin)
+	if err := d.newRPCServer(plugin); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = d.rpcServer.Stop() })
+	return plugin
+}
+
+// down 模拟核心服务下发消息
+func down(d *PluginService, deviceId string, op pb_thingmodel.OperationType, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = d.rpcServer.ThingModelMsgDown(context.Background(), &pb_thingmodel.ThingModelMsgDownRequest{
+		DeviceId:      deviceId,
+		OperationType: op,
+		Data:          string(data),
+	})
+	return err
+}
+
+func TestReportsGoThroughUplink(t *testing.T) {
+	d, core := newTestService(t)
+
+	event := model.NewEventReport(model.NewEventData("high", map[string]interface{}{"value": 90}))
+	resp, err := d.EventReport("d1", event)
+	if err != nil || !resp.Success {
+		t.Fatalf("EventReport() = %+v, %v", resp, err)
+	}
+	resp, err = d.PropertyReport("d1", model.NewPropertyReport("", 0, map[string]interface{}{"temp": 20}))
+	if err != nil || !resp.Success {
+		t.Fatalf("PropertyReport() = %+v, %v", resp, err)
+	}
+
+	events := core.uplinks(pb_thingmodel.OperationType_EVENT_REPORT)
+	if len(events) != 1 || events[0].GetDeviceId() != "d1" || events[0].GetBaseRequest().GetPluginId() != "test" {
+		t.Fatalf("event uplinks = %v", events)
+	}
+	var got model.EventReport
+	decodeUp(t, events[0], &got)
+	if got.MsgId == "" || got.Data.Identifier != "high" {
+		t.Fatalf("event report = %+v, want generated msgId and identifier high", got)
+	}
+	if n := len(core.uplinks(pb_thingmodel.OperationType_PROPERTY_REPORT)); n != 1 {
+		t.Fatalf("property uplinks = %d, want 1", n)
+	}
+	if stats := d.GetUplinkStats(); stats != (model.UplinkStats{}) {
+		t.Fatalf("GetUplinkStats() = %+v, want no counters without rate limit", stats)
+	}
+}
+
+func TestReportRejectedByCore(t *testing.T) {
+	d, core := newTestService(t)
+	core.setReply(func(*pb_thingmodel.ThingModelMsgUpRequest) *pb_common.CommonResponse {
+		return &pb_common.CommonResponse{Success: false, Code: "500", Message: "busy"}
+	})
+	resp, err := d.EventReport("d1", model.NewEventReport(model.NewEventData("high", nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Success || resp.ErrorMessage != "busy" {
+		t.Fatalf("EventReport() = %+v, want rejection from core", resp)
+	}
+}
@@ -0,0 +1,114 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ytuox/elink-sdk-go/model"
+)
+
+func (d *PluginService) diffPropertyShadow(deviceId string, local map[string]model.PropertyData) (model.ShadowDiff, error) {
+	if len(deviceId) == 0 {
+		return model.ShadowDiff{}, errors.New("required device id")
+	}
+	if local == nil {
+		local = d.twinCache.All(deviceId)
+	}
+	shadow, err := d.getDevicePropertyShadow(deviceId, "")
+	if err != nil {
+		return model.ShadowDiff{}, err
+	}
+	return model.DiffPropertyShadow(shadow, local), nil
+}
+
+// reportShadowDiff 将与影子不一致的本地属性值按原采样时间重新上报,不经过变化上报过滤
+func (d *PluginService) reportShadowDiff(deviceId string, diff model.ShadowDiff) (model.CommonResponse, error) {
+	groups := diff.LocalValues()
+	if len(groups) == 0 {
+		return model.CommonResponse{Success: true}, nil
+	}
+	timestamps := make([]int64, 0, len(groups))
+	for ts := range groups {
+		timestamps = append(timestamps, ts)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	var messages []string
+	limit := d.limit()
+	for _, ts := range timestamps {
+		chunks, err := splitData(groups[ts], limit)
+		if err != nil {
+			return model.CommonResponse{}, err
+		}
+		if ts <= 0 {
+			// 没有采样时间的本地值使用当前时间
+			ts = d.now().UnixMilli()
+		}
+		for _, chunk := range chunks {
+			resp, err := d.sendPropertyReport(deviceId, model.NewPropertyReport("", ts, chunk))
+			if err != nil {
+				return model.CommonResponse{}, err
+			}
+			if !resp.Success {
+				messages = append(messages, resp.ErrorMessage)
+			}
+		}
+	}
+	return model.CommonResponse{
+		ErrorMessage: strings.Join(messages, "; "),
+		Success:      len(messages) == 0,
+	}, nil
+}
+
+// applyShadowDiff 将影子中与本地不同的可写属性值通过 HandlePropertySet 重新下发给设备
+func (d *PluginService) applyShadowDiff(deviceId string, diff model.ShadowDiff) error {
+	if d.rpcServer == nil {
+		return errors.New("plugin service not started")
+	}
+	device, ok := d.deviceCache.SearchById(deviceId)
+	if !ok {
+		return fmt.Errorf("device(%s) not found", deviceId)
+	}
+
+	req := model.PropertySet{
+		CommonRequest: model.CommonRequest{
+			Version:   model.Version,
+			Timestamp: d.now().UnixMilli(),
+		},
+		Data: make(map[string]interface{}, len(diff.Changed)),
+		Spec: make(map[string]model.Property, len(diff.Changed)),
+	}
+	for id, v := range diff.ShadowValues() {
+		spec, ok := d.productCache.GetPropertySpecByIdentifier(device.ProductId, id)
+		if ok && spec.ReadOnly() {
+			continue
+		}
+		req.Data[id] = v
+		req.Spec[id] = spec
+	}
+	if len(req.Data) == 0 {
+		return nil
+	}
+	d.fillMsgId(&req.MsgId)
+	return d.rpcServer.HandleDownlink(d.ctx, &model.DownlinkMessage{
+		Type:        model.DownlinkPropertySet,
+		Device:      device,
+		PropertySet: &req,
+	})
+}
@@ -0,0 +1,84 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/ytuox/elink-sdk-go/model"
+
+	pb_thingmodel "github.com/ytuox/elink-plugin-proto/thingmodel"
+)
+
+func TestShadowDiffReportAndApply(t *testing.T) {
+	d, core := newTestService(t)
+	plugin := withPlugin(t, d)
+	core.setShadow([]model.PropertyShadowData{
+		{Identifier: "temp", PropertyData: model.PropertyData{Value: 20, Timestamp: 1000}},
+		{Identifier: "serial", PropertyData: model.PropertyData{Value: "s1", Timestamp: 1000}},
+		{Identifier: "removed", PropertyData: model.PropertyData{Value: 1, Timestamp: 1000}},
+	})
+	if _, err := d.PropertyReport("d1", model.NewPropertyReport("", 2000, map[string]interface{}{"temp": 25, "serial": "s2", "humidity": 50})); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未指定本地状态时与设备孪生比较
+	diff, err := d.DiffPropertyShadow("d1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changed, missing []string
+	for _, item := range diff.Changed {
+		changed = append(changed, item.Identifier)
+	}
+	for _, item := range diff.Missing {
+		missing = append(missing, item.Identifier)
+	}
+	if !reflect.DeepEqual(changed, []string{"serial", "temp"}) || !reflect.DeepEqual(missing, []string{"humidity"}) || len(diff.Stale) != 0 {
+		t.Fatalf("DiffPropertyShadow() = %+v, want serial and temp changed, humidity missing", diff)
+	}
+
+	reports := len(core.uplinks(pb_thingmodel.OperationType_PROPERTY_REPORT))
+	if resp, err := d.ReportShadowDiff("d1", diff); err != nil || !resp.Success {
+		t.Fatalf("ReportShadowDiff() = %+v, %v", resp, err)
+	}
+	ups := core.uplinks(pb_thingmodel.OperationType_PROPERTY_REPORT)
+	if len(ups) != reports+1 {
+		t.Fatalf("property uplinks = %d, want one re-report", len(ups)-reports)
+	}
+	var got model.PropertyReport
+	decodeUp(t, ups[reports], &got)
+	want := map[string]interface{}{"temp": float64(25), "serial": "s2", "humidity": float64(50)}
+	if got.Timestamp != 2000 || !reflect.DeepEqual(got.Data, want) {
+		t.Fatalf("re-report = %+v, want local values at their sample time", got)
+	}
+
+	// 只读属性不重新下发给设备
+	if err := d.ApplyShadowDiff("d1", diff); err != nil {
+		t.Fatal(err)
+	}
+	plugin.mu.Lock()
+	defer plugin.mu.Unlock()
+	if len(plugin.sets) != 1 || !reflect.DeepEqual(plugin.sets[0].Data, map[string]interface{}{"temp": json.Number("20")}) {
+		t.Fatalf("plugin property sets = %+v, want shadow temp only", plugin.sets)
+	}
+
+	local := map[string]model.PropertyData{"temp": {Value: 20, Timestamp: 1000}}
+	if diff, err := d.DiffPropertyShadowWith("d1", local); err != nil || !diff.Empty() {
+		t.Fatalf("DiffPropertyShadowWith() = %+v, %v, want empty", diff, err)
+	}
+}
@@ -0,0 +1,113 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"errors"
+
+	"github.com/ytuox/elink-sdk-go/internal/cache"
+	"github.com/ytuox/elink-sdk-go/pkg/storage"
+)
+
+// secureNamespace 加密存储的命名空间,加密只作用于该命名空间下的键
+const secureNamespace = "secure"
+
+// secureStorage 首次调用时派生密钥,之后复用加密存储
+func (d *PluginService) secureStorage() (*storage.Store, error) {
+	d.secureOnce.Do(func() {
+		backend, err := storage.NewEncryptedBackend(d, secureNamespace+"/", d.cfg.Storage.Secret, d.cfg.Storage.OldSecrets...)
+		if err != nil {
+			d.secureErr = err
+			return
+		}
+		d.secureStore = storage.New(backend).WithDirect(backend.WithBackend(rawStorage{d})).Namespace(secureNamespace)
+	})
+	return d.secureStore, d.secureErr
+}
+
+// enableStorageCache 加载全部自定义存储到本地缓存,与核心服务重新连接后重新加载
+func (d *PluginService) enableStorageCache(maxEntries int) error {
+	if d.storageCache != nil {
+		return errors.New("storage cache already enabled")
+	}
+	c := cache.NewStorageCache(maxEntries)
+	gen := c.Invalidate()
+	kvs, err := d.fetchAllStorage()
+	if err != nil {
+		return err
+	}
+	c.Load(kvs, gen)
+	d.storageCache = c
+	d.rpcClient.WatchReconnect(d.ctx, d.reloadStorageCache)
+	return nil
+}
+
+func (d *PluginService) reloadStorageCache() {
+	gen := d.storageCache.Invalidate()
+	kvs, err := d.fetchAllStorage()
+	if err != nil {
+		d.logger.Errorf("reload storage cache error: %s", err)
+		return
+	}
+	d.storageCache.Load(kvs, gen)
+}
+
+func (d *PluginService) getCustomStorage(keys []string) (map[string][]byte, error) {
+	if d.storageCache == nil || len(keys) == 0 {
+		return d.fetchStorage(keys)
+	}
+	hits, misses, gen := d.storageCache.Get(keys)
+	if len(misses) == 0 {
+		return hits, nil
+	}
+	kvs, err := d.fetchStorage(misses)
+	if err != nil {
+		return nil, err
+	}
+	d.storageCache.Fill(kvs, gen)
+	for k, v := range kvs {
+		hits[k] = v
+	}
+	return hits, nil
+}
+
+func (d *PluginService) putCustomStorage(kvs map[string][]byte) error {
+	if err := d.storeStorage(kvs); err != nil {
+		return err
+	}
+	if d.storageCache != nil {
+		d.storageCache.Put(kvs)
+	}
+	return nil
+}
+
+func (d *PluginService) deleteCustomStorage(keys []string) error {
+	if err := d.removeStorage(keys); err != nil {
+		return err
+	}
+	if d.storageCache != nil {
+		d.storageCache.Delete(keys)
+	}
+	return nil
+}
+
+func (d *PluginService) getAllCustomStorage() (map[string][]byte, error) {
+	if d.storageCache != nil {
+		if kvs, ok := d.storageCache.All(); ok {
+			return kvs, nil
+		}
+	}
+	return d.fetchAllStorage()
+}
@@ -0,0 +1,100 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStorageCache(t *testing.T) {
+	d, core := newTestService(t)
+	core.kvs["a"] = []byte("1")
+	core.kvs["b"] = []byte("2")
+	if err := d.EnableStorageCache(10); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.EnableStorageCache(10); err == nil {
+		t.Fatal("EnableStorageCache() twice succeeded")
+	}
+	reads := core.storageReads()
+
+	kvs, err := d.GetCustomStorage([]string{"a", "missing"})
+	if err != nil || !reflect.DeepEqual(kvs, map[string][]byte{"a": []byte("1")}) {
+		t.Fatalf("GetCustomStorage() = %v, %v", kvs, err)
+	}
+	if kvs, err := d.GetAllCustomStorage(); err != nil || len(kvs) != 2 {
+		t.Fatalf("GetAllCustomStorage() = %v, %v", kvs, err)
+	}
+	if n := core.storageReads(); n != reads {
+		t.Fatalf("storage reads = %d, want hits served from the cache", n-reads)
+	}
+
+	// 写入与删除同步到核心服务后更新缓存
+	if err := d.PutCustomStorage(map[string][]byte{"a": []byte("3")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.DeleteCustomStorage([]string{"b"}); err != nil {
+		t.Fatal(err)
+	}
+	kvs, err = d.GetCustomStorage([]string{"a", "b"})
+	if err != nil || !reflect.DeepEqual(kvs, map[string][]byte{"a": []byte("3")}) {
+		t.Fatalf("GetCustomStorage() after writes = %v, %v, want the written value", kvs, err)
+	}
+	if n := core.storageReads(); n != reads {
+		t.Fatalf("storage reads = %d, want reads after writes served from the cache", n-reads)
+	}
+	core.mu.Lock()
+	_, deleted := core.kvs["b"]
+	stored := string(core.kvs["a"])
+	core.mu.Unlock()
+	if deleted || stored != "3" {
+		t.Fatal("writes were not sent to the core")
+	}
+
+	// 重新连接后重新加载核心服务的数据
+	core.mu.Lock()
+	core.kvs["c"] = []byte("4")
+	core.mu.Unlock()
+	d.reloadStorageCache()
+	kvs, err = d.GetCustomStorage([]string{"c"})
+	if err != nil || string(kvs["c"]) != "4" {
+		t.Fatalf("GetCustomStorage() after reload = %v, %v, want data changed on the core", kvs, err)
+	}
+}
+
+func TestStorageCacheMissesReadCore(t *testing.T) {
+	d, core := newTestService(t)
+	for _, k := range []string{"a", "b", "c"} {
+		core.kvs[k] = []byte(k)
+	}
+	// 数据超出容量时只缓存部分数据,未命中的键从核心服务读取后缓存
+	if err := d.EnableStorageCache(2); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		kvs, err := d.GetCustomStorage([]string{"a", "b", "c"})
+		if err != nil || len(kvs) != 3 {
+			t.Fatalf("GetCustomStorage() = %v, %v", kvs, err)
+		}
+	}
+	if kvs, err := d.GetAllCustomStorage(); err != nil || len(kvs) != 3 {
+		t.Fatalf("GetAllCustomStorage() = %v, %v, want all data from the core", kvs, err)
+	}
+	// 加载1次,每次查询未命中1个键,部分缓存时读取全部数据1次
+	if n := core.storageReads(); n != 4 {
+		t.Fatalf("storage reads = %d, want 4", n)
+	}
+}
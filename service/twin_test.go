@@ -0,0 +1,144 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/model"
+
+	pb_device "github.com/ytuox/elink-plugin-proto/device"
+	pb_device_callback "github.com/ytuox/elink-plugin-proto/devicecallback"
+	pb_thingmodel "github.com/ytuox/elink-plugin-proto/thingmodel"
+)
+
+// propertyGet 下发属性查询
+func propertyGet(t *testing.T, d *PluginService, msgId string, identifiers ...string) {
+	t.Helper()
+	req := model.PropertyGet{CommonRequest: model.CommonRequest{MsgId: msgId}, Data: identifiers}
+	if err := down(d, "d1", pb_thingmodel.OperationType_PROPERTY_GET, req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPropertyGetAnsweredFromTwin(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	d, core := newTestService(t, WithClock(func() time.Time { return now }))
+	plugin := withPlugin(t, d)
+	policy := model.NewTwinPolicy(10 * time.Second)
+	policy.MaxAge["humidity"] = 0
+	if err := d.SetTwinPolicy("p1", policy); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.PropertyReport("d1", model.NewPropertyReport("", 0, map[string]interface{}{"temp": 20, "humidity": 50})); err != nil {
+		t.Fatal(err)
+	}
+
+	propertyGet(t, d, "g1", "temp")
+	ups := core.uplinks(pb_thingmodel.OperationType_PROPERTY_GET_RESPONSE)
+	if len(ups) != 1 {
+		t.Fatalf("property get responses = %d, want 1", len(ups))
+	}
+	var resp model.PropertyGetResponse
+	decodeUp(t, ups[0], &resp)
+	if resp.MsgId != "g1" || len(resp.Data) != 1 || resp.Data[0].Identifier != "temp" ||
+		resp.Data[0].Value != float64(20) || resp.Data[0].Timestamp != now.UnixMilli() {
+		t.Fatalf("property get response = %+v, want temp 20 from twin", resp)
+	}
+	if _, gets, _ := plugin.calls(); gets != 0 {
+		t.Fatalf("plugin got %d property gets, want answered from twin", gets)
+	}
+
+	// 有属性未配置时效或从未上报时交给插件处理
+	propertyGet(t, d, "g2", "temp", "humidity")
+	propertyGet(t, d, "g3", "temp", "serial")
+	if _, gets, _ := plugin.calls(); gets != 2 {
+		t.Fatalf("plugin got %d property gets, want 2", gets)
+	}
+
+	// 超过时效后交给插件处理
+	now = now.Add(10*time.Second + time.Millisecond)
+	propertyGet(t, d, "g4", "temp")
+	if _, gets, _ := plugin.calls(); gets != 3 {
+		t.Fatalf("plugin got %d property gets, want stale value passed to plugin", gets)
+	}
+	if n := len(core.uplinks(pb_thingmodel.OperationType_PROPERTY_GET_RESPONSE)); n != 1 {
+		t.Fatalf("property get responses = %d, want 1", n)
+	}
+}
+
+func TestTwinInvalidation(t *testing.T) {
+	d, core := newTestService(t)
+	plugin := withPlugin(t, d)
+	if err := d.SetTwinPolicy("p1", model.NewTwinPolicy(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetTwinPolicy("p2", model.NewTwinPolicy(time.Minute)); err == nil {
+		t.Fatal("SetTwinPolicy() of unknown product succeeded")
+	}
+	report := func() {
+		t.Helper()
+		if _, err := d.PropertyReport("d1", model.NewPropertyReport("", 0, map[string]interface{}{"temp": 20})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		invalidate func(t *testing.T)
+	}{
+		{
+			name: "device deleted",
+			invalidate: func(t *testing.T) {
+				if _, err := d.rpcServer.DeleteDeviceCallback(ctx, &pb_device_callback.DeleteDeviceCallbackRequest{DeviceId: "d1"}); err != nil {
+					t.Fatal(err)
+				}
+				if twin := d.GetDeviceTwin("d1"); len(twin) != 0 {
+					t.Fatalf("GetDeviceTwin() after delete = %v, want empty", twin)
+				}
+				if _, err := d.rpcServer.CreateDeviceCallback(ctx, &pb_device_callback.CreateDeviceCallbackRequest{
+					Data: &pb_device.Device{Id: "d1", ProductId: "p1"},
+				}); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "policy removed",
+			invalidate: func(*testing.T) {
+				d.RemoveTwinPolicy("p1")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report()
+			answered := len(core.uplinks(pb_thingmodel.OperationType_PROPERTY_GET_RESPONSE))
+			_, before, _ := plugin.calls()
+
+			tt.invalidate(t)
+			propertyGet(t, d, tt.name, "temp")
+			if _, gets, _ := plugin.calls(); gets != before+1 {
+				t.Fatalf("plugin got %d property gets, want %d", gets, before+1)
+			}
+			if n := len(core.uplinks(pb_thingmodel.OperationType_PROPERTY_GET_RESPONSE)); n != answered {
+				t.Fatalf("property get responses = %d, want %d", n, answered)
+			}
+		})
+	}
+}
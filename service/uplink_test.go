@@ -0,0 +1,151 @@
+/*******************************************************************************
+ * Copyright 2017.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except
+ * in compliance with the License. You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed under the License
+ * is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+ * or implied. See the License for the specific language governing permissions and limitations under
+ * the License.
+ *******************************************************************************/
+
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ytuox/elink-sdk-go/model"
+
+	pb_common "github.com/ytuox/elink-plugin-proto/common"
+	pb_thingmodel "github.com/ytuox/elink-plugin-proto/thingmodel"
+)
+
+func report(temp interface{}) model.PropertyReport {
+	return model.NewPropertyReport("", 0, map[string]interface{}{"temp": temp})
+}
+
+func TestRateLimitPolicy(t *testing.T) {
+	d, core := newTestService(t)
+	d.SetRateLimitPolicy(model.RateLimitPolicy{DeviceRate: 0.001, DeviceBurst: 1, MaxDelay: time.Millisecond})
+
+	if _, err := d.PropertyReport("d1", report(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.PropertyReport("d1", report(2)); err == nil || !strings.Contains(err.Error(), "rate limited") {
+		t.Fatalf("PropertyReport() error = %v, want rate limited", err)
+	}
+	// 设备限流只作用于各自设备的属性上报
+	if _, err := d.PropertyReport("d2", report(3)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.EventReport("d1", model.NewEventReport(model.NewEventData("high", nil))); err != nil {
+		t.Fatalf("EventReport() error = %v, want events to bypass the device limit", err)
+	}
+	if stats := d.GetUplinkStats(); stats.Sent != 3 || stats.Dropped != 1 {
+		t.Fatalf("GetUplinkStats() = %+v, want 3 sent and 1 dropped", stats)
+	}
+
+	d.DisableRateLimit()
+	if _, err := d.PropertyReport("d1", report(4)); err != nil {
+		t.Fatalf("PropertyReport() after DisableRateLimit error = %v", err)
+	}
+	if n := len(core.uplinks(pb_thingmodel.OperationType_PROPERTY_REPORT)); n != 3 {
+		t.Fatalf("property uplinks = %d, want 3", n)
+	}
+}
+
+func TestSetUplinkLimitConcurrently(t *testing.T) {
+	d, core := newTestService(t)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= 20; i++ {
+			d.SetUplinkLimit(model.UplinkLimit{MaxKeys: i})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			samples := map[string][]model.PropertyData{"temp": {{Value: i, Timestamp: int64(i + 1)}}}
+			if _, err := d.PropertyBackfill("d1", samples); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	wg.Wait()
+
+	d.SetUplinkLimit(model.UplinkLimit{MaxKeys: 1})
+	batch := model.NewBatchReport(model.BatchData{Properties: map[string]model.BatchProperty{
+		"temp":     {Value: 1},
+		"humidity": {Value: 2},
+	}})
+	result, err := d.BatchReport("d1", batch)
+	if err != nil || result.Messages != 2 {
+		t.Fatalf("BatchReport() = %+v, %v, want 2 messages with MaxKeys 1", result, err)
+	}
+	if n := len(core.uplinks(pb_thingmodel.OperationType_DATA_BATCH_REPORT)); n != 2 {
+		t.Fatalf("batch uplinks = %d, want 2", n)
+	}
+}
+
+func TestReportWaitsForAck(t *testing.T) {
+	d, core := newTestService(t)
+	withPlugin(t, d)
+	d.SetAckTimeout(100 * time.Millisecond)
+
+	acks := map[string]string{
+		"ok":     `{"msgId":"ok","success":true}`,
+		"failed": `{"msgId":"failed","success":false,"code":500,"errorMessage":"rejected"}`,
+	}
+	core.setReply(func(up *pb_thingmodel.ThingModelMsgUpRequest) *pb_common.CommonResponse {
+		var r model.PropertyReport
+		_ = json.Unmarshal([]byte(up.GetData()), &r)
+		if data, ok := acks[r.MsgId]; ok {
+			// 核心服务在应答上报请求后异步下发确认
+			go func() {
+				if err := down(d, up.GetDeviceId(), pb_thingmodel.OperationType_PROPERTY_REPORT_RESPONSE, json.RawMessage(data)); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		return &pb_common.CommonResponse{Success: true}
+	})
+	acked := func(msgId string) model.PropertyReport {
+		r := model.NewPropertyReport(msgId, 0, map[string]interface{}{"temp": 20})
+		r.Sys = model.AckRequired()
+		return r
+	}
+
+	resp, err := d.PropertyReport("d1", acked("ok"))
+	if err != nil || !resp.Success {
+		t.Fatalf("PropertyReport() = %+v, %v, want acked", resp, err)
+	}
+	resp, err = d.PropertyReport("d1", acked("failed"))
+	if err != nil || resp.Success || resp.Code != "500" || resp.ErrorMessage != "rejected" {
+		t.Fatalf("PropertyReport() = %+v, %v, want the failure from the ack", resp, err)
+	}
+
+	start := time.Now()
+	_, err = d.PropertyReport("d1", acked("lost"))
+	if err == nil || !strings.Contains(err.Error(), "message(lost): wait for ack timeout") {
+		t.Fatalf("PropertyReport() error = %v, want ack timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("PropertyReport() returned after %s, want the ack timeout", elapsed)
+	}
+	// 超时后到达的确认不影响后续消息
+	if err := down(d, "d1", pb_thingmodel.OperationType_PROPERTY_REPORT_RESPONSE, model.CommonRequest{MsgId: "lost"}); err != nil {
+		t.Fatalf("late ack error = %v", err)
+	}
+	if resp, err := d.PropertyReport("d1", report(21)); err != nil || !resp.Success {
+		t.Fatalf("PropertyReport() without ack = %+v, %v", resp, err)
+	}
+}